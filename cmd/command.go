@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//Exit codes
+const (
+	ExitOK        = 0
+	ExitError     = 1
+	ExitUsage     = 2
+	ExitUpstream  = 3
+	ExitNoResults = 4
+)
+
+//errNoResults is returned by commands when nothing matched, e.g. after filtering
+var errNoResults = errors.New("no results")
+
+//usageError is returned by commands when the arguments are wrong
+type usageError struct {
+	error
+}
+
+func usagef(format string, a ...interface{}) error {
+	return usageError{fmt.Errorf(format, a...)}
+}
+
+//Command is a subcommand with its own flags
+type Command struct {
+	Name    string
+	Aliases []string
+	Args    string
+	Short   string
+	//NArgs is the number of positional arguments, -1 for any
+	NArgs int
+	Flags *flag.FlagSet
+	Run   func(args []string) error
+}
+
+//NewCommand creates a command, add flags to its FlagSet and set Run
+func NewCommand(name, args, short string, nargs int) *Command {
+	c := &Command{Name: name, Args: args, Short: short, NArgs: nargs}
+	c.Flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	c.Flags.SetOutput(os.Stderr)
+	c.Flags.Usage = func() {}
+	return c
+}
+
+//PrintUsage prints the command's synopsis and flags
+func (c *Command) PrintUsage(w io.Writer) {
+	hasFlags := false
+	c.Flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+
+	synopsis := c.Name
+	if hasFlags {
+		synopsis += " [flags]"
+	}
+	fmt.Fprintf(w, "Usage: skanetrafiken %s %s\n\n%s\n", synopsis, c.Args, c.Short)
+
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		c.Flags.SetOutput(w)
+		c.Flags.PrintDefaults()
+		c.Flags.SetOutput(os.Stderr)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:\n  --format string\n    \toutput format: json, geojson, csv, table, kml or gpx")
+}
+
+//Execute parses args, runs the command and returns the exit code
+func (c *Command) Execute(args []string) int {
+
+	pos, err := parseInterspersed(c.Flags, args)
+	if err == flag.ErrHelp {
+		c.PrintUsage(os.Stdout)
+		return ExitOK
+	}
+	if err == nil && c.NArgs >= 0 && len(pos) != c.NArgs {
+		err = fmt.Errorf("expected %s", c.Args)
+		fmt.Fprintf(os.Stderr, "skanetrafiken %s: %v\n", c.Name, err)
+	}
+	if err != nil {
+		c.PrintUsage(os.Stderr)
+		return ExitUsage
+	}
+
+	if err = c.Run(pos); err != nil {
+		fmt.Fprintf(os.Stderr, "skanetrafiken %s: %v\n", c.Name, err)
+		code := exitCode(err)
+		if code == ExitUsage {
+			c.PrintUsage(os.Stderr)
+		}
+		return code
+	}
+	return ExitOK
+}
+
+//exitCode tells usage errors, empty results and upstream failures apart
+func exitCode(err error) int {
+	var apiErr *openapi.APIError
+	switch {
+	case errors.As(err, &usageError{}), errors.Is(err, openapi.ErrOutOfBounds):
+		return ExitUsage
+	case errors.Is(err, errNoResults),
+		errors.Is(err, openapi.ErrNoStationsFound),
+		errors.Is(err, openapi.ErrNoJourneysFound),
+		errors.Is(err, openapi.ErrNoDeparturesFound),
+		errors.Is(err, openapi.ErrNoPathFound):
+		return ExitNoResults
+	case errors.As(err, &apiErr):
+		return ExitUpstream
+	}
+	return ExitError
+}
+
+//parseInterspersed parses flags that may come before, between or after positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+//App is the list of commands
+type App []*Command
+
+//Lookup finds a command by name or alias
+func (app App) Lookup(name string) *Command {
+	for _, c := range app {
+		if c.Name == name {
+			return c
+		}
+		for _, a := range c.Aliases {
+			if a == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func (app App) PrintUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: skanetrafiken [--format json|geojson|csv|table|kml|gpx] <command> [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range app {
+		fmt.Fprintf(w, "  %-12s%s\n", c.Name, c.Short)
+	}
+	fmt.Fprintln(w, "\nRun \"skanetrafiken help <command>\" for the arguments and flags of a command.")
+	fmt.Fprintf(w, "\nExit codes: %d ok, %d error, %d usage, %d upstream error, %d no results\n",
+		ExitOK, ExitError, ExitUsage, ExitUpstream, ExitNoResults)
+}
+
+//Execute runs the command named by args[0] and returns the exit code
+func (app App) Execute(args []string) int {
+
+	if len(args) == 0 {
+		app.PrintUsage(os.Stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help":
+		app.PrintUsage(os.Stdout)
+		return ExitOK
+	}
+
+	c := app.Lookup(args[0])
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "skanetrafiken: unknown command %q\n\n", args[0])
+		app.PrintUsage(os.Stderr)
+		return ExitUsage
+	}
+	return c.Execute(args[1:])
+}
+
+//HelpCommand prints the usage of the app or one of its commands
+func HelpCommand(app *App) *Command {
+	c := NewCommand("help", "[command]", "Show help for a command", -1)
+	c.Run = func(args []string) error {
+		if len(args) == 0 {
+			app.PrintUsage(os.Stdout)
+			return nil
+		}
+		cmd := app.Lookup(args[0])
+		if cmd == nil {
+			return usagef("unknown command %q", args[0])
+		}
+		cmd.PrintUsage(os.Stdout)
+		return nil
+	}
+	return c
+}
+
+//flagNames returns the flags of a command, as --name
+func flagNames(c *Command) []string {
+	names := []string{"--help", "--format"}
+	c.Flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "--"+f.Name)
+	})
+	sort.Strings(names[2:])
+	return names
+}
+
+//CompletionCommand prints a shell completion script
+func CompletionCommand(app *App) *Command {
+	c := NewCommand("completion", "bash|zsh|fish", "Print shell completion script, e.g. source <(skanetrafiken completion bash)", 1)
+	c.Run = func(args []string) error {
+		switch args[0] {
+		case "bash":
+			writeBashCompletion(os.Stdout, *app)
+		case "zsh":
+			fmt.Fprintln(os.Stdout, "autoload -U +X bashcompinit && bashcompinit")
+			writeBashCompletion(os.Stdout, *app)
+		case "fish":
+			writeFishCompletion(os.Stdout, *app)
+		default:
+			return usagef("unsupported shell %q", args[0])
+		}
+		return nil
+	}
+	return c
+}
+
+func writeBashCompletion(w io.Writer, app App) {
+	var names []string
+	for _, c := range app {
+		names = append(names, c.Name)
+	}
+
+	fmt.Fprintln(w, `_skanetrafiken() {
+	local cur prev cmd i
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	if [ "$prev" = "--format" ]; then
+		COMPREPLY=($(compgen -W "json geojson csv table kml gpx" -- "$cur"))
+		return
+	fi
+	cmd=""
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "${COMP_WORDS[i]}" in
+		--format) i=$((i + 1)) ;;
+		-*) ;;
+		*) cmd="${COMP_WORDS[i]}"; break ;;
+		esac
+	done
+	case "$cmd" in`)
+	fmt.Fprintf(w, "\t\"\") COMPREPLY=($(compgen -W \"%s --format --help\" -- \"$cur\")) ;;\n", strings.Join(names, " "))
+	for _, c := range app {
+		words := flagNames(c)
+		if c.Name == "help" {
+			words = append(words, names...)
+		}
+		if c.Name == "completion" {
+			words = append(words, "bash", "zsh", "fish")
+		}
+		fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n",
+			strings.Join(append([]string{c.Name}, c.Aliases...), "|"), strings.Join(words, " "))
+	}
+	fmt.Fprintln(w, `	esac
+}
+complete -o default -F _skanetrafiken skanetrafiken`)
+}
+
+func writeFishCompletion(w io.Writer, app App) {
+	fmt.Fprintln(w, "complete -c skanetrafiken -l format -x -a 'json geojson csv table kml gpx' -d 'Output format'")
+	for _, c := range app {
+		fmt.Fprintf(w, "complete -c skanetrafiken -n __fish_use_subcommand -x -a %s -d %q\n", c.Name, c.Short)
+		c.Flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(w, "complete -c skanetrafiken -n '__fish_seen_subcommand_from %s' -l %s -d %q\n", c.Name, f.Name, f.Usage)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//captureOutput runs fn and returns what it wrote to stdout, stderr is discarded
+func captureOutput(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, devNull
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestExitCode(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{usagef("expected %s", "<from> <to>"), ExitUsage},
+		{fmt.Errorf("journeys: %w", usagef("bad time")), ExitUsage},
+		{fmt.Errorf("%w: 99, 99", openapi.ErrOutOfBounds), ExitUsage},
+		{errNoResults, ExitNoResults},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: openapi.ErrNoStationsFound}, ExitNoResults},
+		{&openapi.APIError{Endpoint: openapi.RESULTSPAGE, Err: openapi.ErrNoJourneysFound}, ExitNoResults},
+		{&openapi.APIError{Endpoint: openapi.STATIONRESULT, Err: openapi.ErrNoDeparturesFound}, ExitNoResults},
+		{&openapi.APIError{Endpoint: openapi.JOURNEYPATH, Err: openapi.ErrNoPathFound}, ExitNoResults},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: openapi.ErrUpstreamUnavailable}, ExitUpstream},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, HTTPStatus: 500, Err: openapi.ErrUpstreamStatus}, ExitUpstream},
+		{errors.New("write failed"), ExitError},
+	}
+	for _, tt := range tests {
+		if code := exitCode(tt.err); code != tt.want {
+			t.Errorf("exitCode(%v): expected %d, got %d", tt.err, tt.want, code)
+		}
+	}
+}
+
+func TestCommandExecute(t *testing.T) {
+
+	tests := []struct {
+		args []string
+		err  error
+		want int
+	}{
+		{[]string{"Malmö"}, nil, ExitOK},
+		{[]string{"-h"}, nil, ExitOK},
+		{[]string{}, nil, ExitUsage},
+		{[]string{"Malmö", "Lund"}, nil, ExitUsage},
+		{[]string{"--unknown", "Malmö"}, nil, ExitUsage},
+		{[]string{"Malmö"}, usagef("bad station"), ExitUsage},
+		{[]string{"Malmö"}, errNoResults, ExitNoResults},
+		{[]string{"Malmö"}, &openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: openapi.ErrUpstreamUnavailable}, ExitUpstream},
+		{[]string{"Malmö"}, errors.New("boom"), ExitError},
+	}
+	for _, tt := range tests {
+		c := NewCommand("stations", "<name>", "Find stations", 1)
+		c.Flags.Int("limit", 0, "max results")
+		c.Run = func(args []string) error { return tt.err }
+
+		var code int
+		captureOutput(t, func() { code = c.Execute(tt.args) })
+		if code != tt.want {
+			t.Errorf("Execute(%v) with %v: expected %d, got %d", tt.args, tt.err, tt.want, code)
+		}
+	}
+}
+
+func TestAppExecute(t *testing.T) {
+
+	c := NewCommand("stations", "<name>", "Find stations", 1)
+	c.Aliases = []string{"s"}
+	c.Run = func(args []string) error { return nil }
+	app := App{c}
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"stations", "Lund"}, ExitOK},
+		{[]string{"s", "Lund"}, ExitOK},
+		{[]string{"--help"}, ExitOK},
+		{[]string{}, ExitUsage},
+		{[]string{"trains"}, ExitUsage},
+	}
+	for _, tt := range tests {
+		var code int
+		captureOutput(t, func() { code = app.Execute(tt.args) })
+		if code != tt.want {
+			t.Errorf("Execute(%v): expected %d, got %d", tt.args, tt.want, code)
+		}
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+
+	tests := []struct {
+		args  []string
+		pos   []string
+		limit int
+		err   bool
+	}{
+		{[]string{"Malmö", "Lund"}, []string{"Malmö", "Lund"}, 0, false},
+		{[]string{"--limit", "3", "Malmö", "Lund"}, []string{"Malmö", "Lund"}, 3, false},
+		{[]string{"Malmö", "--limit=3", "Lund"}, []string{"Malmö", "Lund"}, 3, false},
+		{[]string{"Malmö", "Lund", "-limit", "3"}, []string{"Malmö", "Lund"}, 3, false},
+		{[]string{"Malmö", "--", "--limit"}, []string{"Malmö", "--limit"}, 0, false},
+		{[]string{}, nil, 0, false},
+		{[]string{"Malmö", "--limit"}, nil, 0, true},
+		{[]string{"Malmö", "--limit", "many"}, nil, 0, true},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		limit := fs.Int("limit", 0, "max results")
+
+		pos, err := parseInterspersed(fs, tt.args)
+		if (err != nil) != tt.err {
+			t.Errorf("parseInterspersed(%v): unexpected error %v", tt.args, err)
+			continue
+		}
+		if strings.Join(pos, ",") != strings.Join(tt.pos, ",") || *limit != tt.limit {
+			t.Errorf("parseInterspersed(%v): expected %v and limit %d, got %v and %d", tt.args, tt.pos, tt.limit, pos, *limit)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+
+	stations := NewCommand("stations", "<name>", "Find stations", 1)
+	stations.Flags.Int("limit", 0, "max results")
+	app := App{stations}
+	app = append(app, HelpCommand(&app), CompletionCommand(&app))
+
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{
+			"complete -o default -F _skanetrafiken skanetrafiken",
+			`compgen -W "json geojson csv table kml gpx"`,
+			`"") COMPREPLY=($(compgen -W "stations help completion --format --help"`,
+			`stations) COMPREPLY=($(compgen -W "--help --format --limit"`,
+			`help) COMPREPLY=($(compgen -W "--help --format stations help completion"`,
+			`completion) COMPREPLY=($(compgen -W "--help --format bash zsh fish"`,
+		}},
+		{"zsh", []string{
+			"autoload -U +X bashcompinit && bashcompinit\n_skanetrafiken() {",
+			"complete -o default -F _skanetrafiken skanetrafiken",
+		}},
+		{"fish", []string{
+			"complete -c skanetrafiken -l format -x -a 'json geojson csv table kml gpx'",
+			`complete -c skanetrafiken -n __fish_use_subcommand -x -a stations -d "Find stations"`,
+			`complete -c skanetrafiken -n '__fish_seen_subcommand_from stations' -l limit -d "max results"`,
+		}},
+	}
+	for _, tt := range tests {
+		var code int
+		out := captureOutput(t, func() { code = app.Execute([]string{"completion", tt.shell}) })
+		if code != ExitOK {
+			t.Errorf("completion %s: expected exit code %d, got %d", tt.shell, ExitOK, code)
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(out, s) {
+				t.Errorf("completion %s: missing %q in\n%s", tt.shell, s, out)
+			}
+		}
+	}
+
+	var code int
+	captureOutput(t, func() { code = app.Execute([]string{"completion", "powershell"}) })
+	if code != ExitUsage {
+		t.Errorf("Expected exit code %d for unsupported shell, got %d", ExitUsage, code)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+//Output formats selected with --format
+const (
+	FormatJSON    = "json"
+	FormatGeoJSON = "geojson"
+	FormatCSV     = "csv"
+	FormatTable   = "table"
+	FormatKML     = "kml"
+	FormatGPX     = "gpx"
+)
+
+//format is the output format given with --format, empty for the command's default
+var format string
+
+//extractFormat removes --format from anywhere in args, so that it works with every command
+func extractFormat(args []string) (string, []string, error) {
+	f := ""
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--format" || a == "-format":
+			if i+1 == len(args) {
+				return "", nil, fmt.Errorf("%s needs a value: json, geojson, csv, table, kml or gpx", a)
+			}
+			f = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--format="):
+			f = strings.TrimPrefix(a, "--format=")
+		case strings.HasPrefix(a, "-format="):
+			f = strings.TrimPrefix(a, "-format=")
+		default:
+			rest = append(rest, a)
+		}
+	}
+
+	switch f {
+	case "", FormatJSON, FormatGeoJSON, FormatCSV, FormatTable, FormatKML, FormatGPX:
+		return f, rest, nil
+	}
+	return "", nil, fmt.Errorf("Unknown format %q, try json, geojson, csv, table, kml or gpx", f)
+}
+
+//outputFormat returns the format to use, def unless --format was given
+func outputFormat(def string) string {
+	if format == "" {
+		return def
+	}
+	return format
+}
+
+//JSONWriter is implemented by the Open API results
+type JSONWriter interface {
+	WriteJSON(w io.Writer) error
+}
+
+//Rows is tabular output, used for both CSV and tables
+type Rows struct {
+	Header []string
+	Rows   [][]string
+}
+
+func (r *Rows) Add(row ...string) {
+	r.Rows = append(r.Rows, row)
+}
+
+func (r Rows) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(r.Header)
+	cw.WriteAll(r.Rows)
+	return cw.Error()
+}
+
+func (r Rows) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.Header, "\t")))
+	for _, row := range r.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+/*
+Output is what a command writes in each format. JSON and GeoJSON are
+written with the WriteJSON methods of the Open API results, or plain
+encoding/json for Value. Nil fields mean the format is not supported.
+*/
+type Output struct {
+	JSON    JSONWriter
+	Value   interface{}
+	GeoJSON JSONWriter
+	Rows    *Rows
+	Text    func(w io.Writer) error
+	KML     func(w io.Writer) error
+	GPX     func(w io.Writer) error
+}
+
+//Write writes out in the format given by --format, or def
+func (out Output) Write(def string) error {
+	w := os.Stdout
+	f := outputFormat(def)
+
+	switch {
+	case f == FormatJSON && out.JSON != nil:
+		return out.JSON.WriteJSON(w)
+	case f == FormatJSON && out.Value != nil:
+		return json.NewEncoder(w).Encode(out.Value)
+	case f == FormatGeoJSON && out.GeoJSON != nil:
+		return out.GeoJSON.WriteJSON(w)
+	case f == FormatCSV && out.Rows != nil:
+		return out.Rows.WriteCSV(w)
+	case f == FormatTable && out.Text != nil:
+		return out.Text(w)
+	case f == FormatTable && out.Rows != nil:
+		return out.Rows.WriteTable(w)
+	case f == FormatKML && out.KML != nil:
+		return out.KML(w)
+	case f == FormatGPX && out.GPX != nil:
+		return out.GPX(w)
+	}
+	return usagef("format %s is not supported by this command", f)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExtractFormat(t *testing.T) {
+
+	tests := []struct {
+		args   []string
+		format string
+		rest   []string
+		err    string
+	}{
+		{[]string{"stations", "Lund"}, "", []string{"stations", "Lund"}, ""},
+		{[]string{"--format", "csv", "stations", "Lund"}, FormatCSV, []string{"stations", "Lund"}, ""},
+		{[]string{"stations", "-format", "table", "Lund"}, FormatTable, []string{"stations", "Lund"}, ""},
+		{[]string{"journeys", "Malmö", "Lund", "--format=geojson"}, FormatGeoJSON, []string{"journeys", "Malmö", "Lund"}, ""},
+		{[]string{"-format=csv", "stations", "Lund"}, FormatCSV, []string{"stations", "Lund"}, ""},
+		{[]string{"path", "1a2b", "--format=kml"}, FormatKML, []string{"path", "1a2b"}, ""},
+		{[]string{"-format=gpx", "path", "1a2b"}, FormatGPX, []string{"path", "1a2b"}, ""},
+		{[]string{"--format=json", "--format", "geojson", "journeys"}, FormatGeoJSON, []string{"journeys"}, ""},
+		{[]string{"stations", "Lund", "--format"}, "", nil, "--format needs a value"},
+		{[]string{"stations", "-format"}, "", nil, "-format needs a value"},
+		{[]string{"--format", "xml", "stations"}, "", nil, `Unknown format "xml"`},
+		{[]string{"--format=", "stations"}, "", []string{"stations"}, ""},
+	}
+	for _, tt := range tests {
+		f, rest, err := extractFormat(tt.args)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("extractFormat(%v): expected error %q, got %v", tt.args, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || f != tt.format || strings.Join(rest, ",") != strings.Join(tt.rest, ",") {
+			t.Errorf("extractFormat(%v): expected %q %v, got %q %v %v", tt.args, tt.format, tt.rest, f, rest, err)
+		}
+	}
+}
+
+func TestOutputWrite(t *testing.T) {
+	defer func() { format = "" }()
+
+	rows := &Rows{Header: []string{"id", "name"}}
+	rows.Add("80000", "Malmö C")
+	out := Output{
+		Value: map[string]int{"id": 80000},
+		Rows:  rows,
+		KML:   func(w io.Writer) error { _, err := io.WriteString(w, "<kml/>\n"); return err },
+	}
+
+	tests := []struct {
+		format, def string
+		want        string
+		err         bool
+	}{
+		{"", FormatTable, "ID     NAME\n80000  Malmö C\n", false},
+		{FormatCSV, FormatTable, "id,name\n80000,Malmö C\n", false},
+		{FormatJSON, FormatTable, "{\"id\":80000}\n", false},
+		{"", FormatCSV, "id,name\n80000,Malmö C\n", false},
+		{"", FormatKML, "<kml/>\n", false},
+		{FormatGPX, FormatTable, "", true},
+		{FormatGeoJSON, FormatTable, "", true},
+	}
+	for _, tt := range tests {
+		format = tt.format
+
+		var err error
+		s := captureOutput(t, func() { err = out.Write(tt.def) })
+		if (err != nil) != tt.err || s != tt.want {
+			t.Errorf("Write with --format %q and default %s: expected %q, got %q %v", tt.format, tt.def, tt.want, s, err)
+		}
+		if err != nil && exitCode(err) != ExitUsage {
+			t.Errorf("Write with --format %q: expected a usage error, got %v", tt.format, err)
+		}
+	}
+}
+
+func TestRowsWriteCSV(t *testing.T) {
+
+	rows := &Rows{Header: []string{"name", "id"}}
+	rows.Add(`Lund "Botulfsplatsen", läge A`, "81217")
+	rows.Add("Malmö C", "80000")
+
+	var buf bytes.Buffer
+	if err := rows.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "name,id\n\"Lund \"\"Botulfsplatsen\"\", läge A\",81217\nMalmö C,80000\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	//Quoted fields read back as they were
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(records) != 3 || records[1][0] != rows.Rows[0][0] {
+		t.Errorf("Unexpected records %q %v", records, err)
+	}
+}
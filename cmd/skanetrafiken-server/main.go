@@ -0,0 +1,137 @@
+/*
+skanetrafiken-server serves the Open API as GeoJSON and JSON over HTTP,
+see package server for the endpoints.
+
+	skanetrafiken-server -addr :8080
+
+With -realtime-stops it also polls the departure boards of the given stop
+areas and serves them as a GTFS-Realtime TripUpdates feed at
+/gtfs-rt/tripupdates.
+
+	skanetrafiken-server -realtime-stops 80000,81216 -realtime-interval 30s
+
+With -stops it answers /nearest from a stop registry saved by package
+stops, without upstream requests.
+
+It shuts down gracefully on SIGINT and SIGTERM, so it can run as a plain
+systemd service.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/gtfs"
+	"github.com/peterstark72/skanetrafiken/openapi"
+	"github.com/peterstark72/skanetrafiken/server"
+	"github.com/peterstark72/skanetrafiken/stops"
+)
+
+func main() {
+
+	addr := flag.String("addr", ":8080", "address to listen on, $PORT overrides the port")
+	origin := flag.String("origin", "*", "Access-Control-Allow-Origin, empty to disable CORS")
+	baseURL := flag.String("base-url", openapi.BaseURL, "Open API base URL")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of each upstream request")
+	cacheSize := flag.Int("cache", 10000, "number of cached upstream responses, 0 to disable")
+	stopsFile := flag.String("stops", "", "stop registry file, see package stops, to answer /nearest locally")
+	realtimeStops := flag.String("realtime-stops", "", "comma separated stop area ids of the GTFS-Realtime feed")
+	realtimeInterval := flag.Duration("realtime-interval", 30*time.Second, "poll interval of the GTFS-Realtime feed")
+	flag.Parse()
+
+	if port := os.Getenv("PORT"); port != "" {
+		*addr = ":" + port
+	}
+
+	opts := []openapi.Option{
+		openapi.WithBaseURL(*baseURL),
+		openapi.WithUserAgent("skanetrafiken-server"),
+		openapi.WithRetry(openapi.DefaultRetryPolicy),
+		openapi.WithCoalescing(true),
+	}
+	if *cacheSize > 0 {
+		opts = append(opts, openapi.WithCache(openapi.NewMemoryCache(*cacheSize)))
+	}
+
+	api := openapi.NewOpenAPI(opts...)
+
+	s := server.New(api)
+	s.AllowOrigin = *origin
+	s.Timeout = *timeout
+
+	if *stopsFile != "" {
+		reg, err := stops.LoadFile(*stopsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.Stops = reg.Index()
+		log.Printf("Loaded %d stop areas from %s", s.Stops.Len(), *stopsFile)
+	}
+
+	stopIDs, err := parseStopIDs(*realtimeStops)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var handler http.Handler = s
+	if len(stopIDs) > 0 {
+		updates := gtfs.NewTripUpdates()
+		go updates.Poll(ctx, api, *realtimeInterval, func(err error) { log.Print(err) }, stopIDs...)
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /gtfs-rt/tripupdates", updates)
+		mux.Handle("/", s)
+		handler = mux
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Listening on %s", *addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Print("Shutting down")
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		log.Fatal(err)
+	}
+}
+
+//parseStopIDs parses a comma separated list of stop area ids
+func parseStopIDs(s string) ([]int, error) {
+	var ids []int
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		id, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, errors.New("invalid stop area id " + strconv.Quote(f))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -1,144 +1,516 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/peterstark72/skanetrafiken/gtfs"
 	"github.com/peterstark72/skanetrafiken/openapi"
-	//"time"
+	"github.com/peterstark72/skanetrafiken/stops"
 )
 
-func PrintPoints(points []openapi.Point) {
-	for _, p := range points {
-		lat, lon := openapi.GridToGeodetic(p.X, p.Y)
-		fmt.Printf("%s,%d,%s,%f,%f\n",
-			p.Name, p.Id, p.Type, lat, lon)
+//ftoa formats a coordinate or other float for CSV and tables
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+func PointRows(points ...[]openapi.Point) *Rows {
+	rows := &Rows{Header: []string{"name", "id", "type", "lat", "lon"}}
+	for _, pp := range points {
+		for _, p := range pp {
+			pos := p.ToWGS84()
+			rows.Add(p.Name, strconv.Itoa(p.Id), p.Type, ftoa(pos.Lat), ftoa(pos.Lon))
+		}
 	}
+	return rows
 }
 
-func PrintNearestStopAreas(points []openapi.NearestStopArea) {
+func NearestStopAreaRows(points []openapi.NearestStopArea) *Rows {
+	rows := &Rows{Header: []string{"name", "id", "type", "lat", "lon", "distance"}}
 	for _, p := range points {
-		lat, lon := openapi.GridToGeodetic(p.X, p.Y)
-		fmt.Printf("%s,%d,%s,%f,%f,%d\n",
-			p.Name, p.Id, "STOP_AREA", lat, lon, p.Distance)
+		pos := p.ToWGS84()
+		rows.Add(p.Name, strconv.Itoa(p.Id), "STOP_AREA", ftoa(pos.Lat), ftoa(pos.Lon), strconv.Itoa(p.Distance))
 	}
+	return rows
 }
 
-func SearchStation() {
+func SearchCommand() *Command {
+	c := NewCommand("search", "<query>", "Search stations by name", 1)
+	c.Run = func(args []string) error {
+
+		api := openapi.NewOpenAPI()
 
-	if len(os.Args) < 3 {
-		fmt.Println("Try search <query>")
-		return
+		result, err := api.QueryStation(args[0])
+		if err != nil {
+			return err
+		}
+
+		out := Output{Value: result.StartPoints, GeoJSON: result, Rows: PointRows(result.StartPoints)}
+		return out.Write(FormatCSV)
 	}
+	return c
+}
 
-	q := os.Args[2]
+func PointsCommand() *Command {
+	c := NewCommand("points", "<start> <end>", "Search start and end points of a journey", 2)
+	c.Run = func(args []string) error {
 
-	api := openapi.NewOpenAPI()
+		api := openapi.NewOpenAPI()
 
-	result, err := api.QueryStation(q)
+		result, err := api.QueryPage(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		out := Output{Value: result, GeoJSON: result, Rows: PointRows(result.StartPoints, result.EndPoints)}
+		return out.Write(FormatCSV)
+	}
+	return c
+}
+
+func NearestCommand() *Command {
+	c := NewCommand("nearest", "<lat>,<lon>", "List stations near a WGS84 position", 1)
+	radius := c.Flags.Int("radius", 1000, "search radius in meters")
+	c.Run = func(args []string) error {
+
+		latlon := strings.Split(args[0], ",")
+		if len(latlon) != 2 {
+			return usagef("expected <lat>,<lon>, got %q", args[0])
+		}
+		coords := [2]float64{}
+		for i, c := range latlon {
+			var err error
+			if coords[i], err = strconv.ParseFloat(strings.TrimSpace(c), 64); err != nil {
+				return usagef("invalid coordinate %q", c)
+			}
+		}
+
+		api := openapi.NewOpenAPI()
+
+		result, err := api.NearestStationLatLon(openapi.LatLon{Lat: coords[0], Lon: coords[1]}, *radius)
+		if err != nil {
+			return err
+		}
+
+		out := Output{Value: result.NearestStopAreas, GeoJSON: result, Rows: NearestStopAreaRows(result.NearestStopAreas)}
+		return out.Write(FormatCSV)
+	}
+	return c
+}
+
+//parseTime parses a time given on the command line, in Europe/Stockholm, defaulting to now
+func parseTime(s string) (time.Time, error) {
+	now := time.Now().In(openapi.Stockholm)
+	if s == "" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, openapi.Stockholm); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.ParseInLocation("15:04", s, openapi.Stockholm)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return t, fmt.Errorf("Invalid time %q, try 15:04 or \"2006-01-02 15:04\"", s)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, openapi.Stockholm), nil
+}
+
+//formatDeviation formats a real-time deviation in minutes, e.g. " (+3 min)"
+func formatDeviation(minutes int, canceled bool) string {
+	switch {
+	case canceled:
+		return " (canceled)"
+	case minutes != 0:
+		return fmt.Sprintf(" (%+d min)", minutes)
 	}
+	return ""
+}
+
+//JourneyRows returns one row per route link
+func JourneyRows(journeys []openapi.Journey) *Rows {
+	rows := &Rows{Header: []string{"journey", "dep", "arr", "from", "to", "line", "no", "towards", "dep_delay", "arr_delay", "canceled"}}
+	for _, j := range journeys {
+		for _, r := range j.RouteLinks {
+			rows.Add(strconv.Itoa(j.SequenceNo),
+				r.DepDateTime.Format(time.RFC3339), r.ArrDateTime.Format(time.RFC3339),
+				r.From.Name, r.To.Name, r.Line.Name, strconv.Itoa(r.Line.No), r.Line.Towards,
+				strconv.Itoa(r.RealTime.DepTimeDeviation), strconv.Itoa(r.RealTime.ArrTimeDeviation),
+				strconv.FormatBool(r.RealTime.Canceled))
+		}
+	}
+	return rows
+}
 
-	PrintPoints(result.StartPoints)
+func PrintJourneys(w io.Writer, journeys []openapi.Journey) {
+	for _, j := range journeys {
+		fmt.Fprintf(w, "%s - %s, %d min, %d changes%s\n",
+			j.DepDateTime.Format("15:04"), j.ArrDateTime.Format("15:04"),
+			int(j.ScheduledDuration().Minutes()), j.NoOfChanges,
+			formatDeviation(j.ArrDelayMinutes(), j.IsCanceled()))
+
+		if j.DepWalkDist > 0 {
+			fmt.Fprintf(w, "  Walk %d m\n", j.DepWalkDist)
+		}
+		for _, r := range j.RouteLinks {
+			fmt.Fprintf(w, "  %s %s%s - %s %s%s, %s %d towards %s\n",
+				r.DepDateTime.Format("15:04"), r.From.Name,
+				formatDeviation(r.RealTime.DepTimeDeviation, r.RealTime.Canceled),
+				r.ArrDateTime.Format("15:04"), r.To.Name,
+				formatDeviation(r.RealTime.ArrTimeDeviation, false),
+				r.Line.Name, r.Line.No, r.Line.Towards)
+		}
+		if j.ArrWalkDist > 0 {
+			fmt.Fprintf(w, "  Walk %d m\n", j.ArrWalkDist)
+		}
+		fmt.Fprintln(w)
+	}
 }
 
-func SearchStartEndPoints() {
+//journeyFlags are the flags shared by the journey and path commands
+type journeyFlags struct {
+	at       *string
+	arriveBy *bool
+	next     *bool
+	prev     *bool
+}
 
-	if len(os.Args) < 4 {
-		fmt.Println("Try points <start> <end>")
-		return
+func newJourneyFlags(c *Command) journeyFlags {
+	return journeyFlags{
+		at:       c.Flags.String("at", "", "time to depart, or arrive by, e.g. 15:04"),
+		arriveBy: c.Flags.Bool("arrive-by", false, "--at is the time to arrive by"),
+		next:     c.Flags.Bool("next", false, "journeys after --at (default)"),
+		prev:     c.Flags.Bool("prev", false, "journeys before --at"),
 	}
+}
 
-	start := os.Args[2]
-	end := os.Args[3]
+//searchJourneys resolves the names of from and to, and finds journeys between them
+func searchJourneys(api openapi.OpenApi, from, to string, f journeyFlags) (res openapi.GetJourneyResult, fr, tp openapi.Point, err error) {
 
-	api := openapi.NewOpenAPI()
+	if *f.next && *f.prev {
+		return res, fr, tp, usagef("--next and --prev cannot be combined")
+	}
 
-	result, err := api.QueryPage(start, end)
+	t, err := parseTime(*f.at)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return res, fr, tp, usageError{err}
 	}
 
-	PrintPoints(result.StartPoints)
-	PrintPoints(result.EndPoints)
+	cmdaction := "next"
+	if *f.prev {
+		cmdaction = "previous"
+	}
+	direction := openapi.DEPARTURE
+	if *f.arriveBy {
+		direction = openapi.ARRIVAL
+	}
 
+	points, err := api.QueryPage(from, to)
+	if err != nil {
+		return res, fr, tp, err
+	}
+
+	fr, tp = points.StartPoints[0], points.EndPoints[0]
+	res, err = api.ResultsPageDirection(cmdaction, fr, tp, t, direction)
+	return res, fr, tp, err
 }
 
-func SearchNearestStations() {
+func JourneyCommand() *Command {
+	c := NewCommand("journey", "<from> <to>", "Find journeys between two places", 2)
+	f := newJourneyFlags(c)
+	c.Run = func(args []string) error {
+
+		api := openapi.NewOpenAPI()
 
-	if len(os.Args) < 3 {
-		fmt.Println("Try nearest <lat>,<lon>")
-		return
+		result, from, to, err := searchJourneys(api, args[0], args[1], f)
+		if err != nil {
+			return err
+		}
+
+		out := Output{JSON: result, GeoJSON: openapi.GeoJsonWriter{GeoJsonObject: result}, Rows: JourneyRows(result.Journeys), Text: func(w io.Writer) error {
+			fmt.Fprintf(w, "%s - %s\n\n", from.Name, to.Name)
+			PrintJourneys(w, result.Journeys)
+			return nil
+		}}
+		return out.Write(FormatTable)
 	}
+	return c
+}
 
-	latlon := strings.Split(os.Args[2], ",")
-	if len(latlon) != 2 {
-		fmt.Println("Try nearest <lat>,<lon>")
-		return
+//PathRows returns one row per coordinate of the path
+func PathRows(parts []openapi.Part) *Rows {
+	rows := &Rows{Header: []string{"part", "line", "from", "to", "lat", "lon"}}
+	for n, p := range parts {
+		for _, c := range p.Coords {
+			pos := c.ToWGS84()
+			rows.Add(strconv.Itoa(n), p.Line.Name, p.From.Name, p.To.Name, ftoa(pos.Lat), ftoa(pos.Lon))
+		}
 	}
-	coords := [2]float64{}
-	for i, c := range latlon {
-		coords[i], _ = strconv.ParseFloat(c, 64)
+	return rows
+}
+
+func PathCommand() *Command {
+	c := NewCommand("path", "<from> <to>", "Show the geographical path of a journey, e.g. --format kml or gpx", 2)
+	f := newJourneyFlags(c)
+	seq := c.Flags.Int("journey", 0, "sequence number of the journey, as listed by the journey command")
+	c.Run = func(args []string) error {
+
+		api := openapi.NewOpenAPI()
+
+		result, _, _, err := searchJourneys(api, args[0], args[1], f)
+		if err != nil {
+			return err
+		}
+
+		path, err := api.JourneyPath(result.JourneyResultKey, *seq)
+		if err != nil {
+			return err
+		}
+
+		parts, err := path.Parts()
+		if err != nil {
+			return err
+		}
+
+		out := Output{JSON: path, GeoJSON: path, Rows: PathRows(parts), KML: path.WriteKML, GPX: path.WriteGPX}
+		return out.Write(FormatGeoJSON)
 	}
+	return c
+}
 
-	x, y := openapi.GeodeticToGrid(coords[0], coords[1])
+//modeAliases maps --mode values to the Swedish TransportModeName
+var modeAliases = map[string]string{
+	"bus":   "Buss",
+	"train": "Tåg",
+	"tram":  "Spårvagn",
+	"ferry": "Färja",
+}
 
-	api := openapi.NewOpenAPI()
+//matchesMode tells if the line's TransportModeName or LineTypeName is mode, e.g. "bus" or "Pågatåg"
+func matchesMode(l openapi.Line, mode string) bool {
+	if alias, ok := modeAliases[strings.ToLower(mode)]; ok {
+		mode = alias
+	}
+	return strings.EqualFold(l.TransportModeName, mode) || strings.EqualFold(l.LineTypeName, mode)
+}
 
-	result, err := api.NearestStation(x, y, 1000)
-	if err != nil {
-		fmt.Println(err)
-		return
+//matchesLine tells if the line has number or name line, e.g. "3" or "Stadsbuss 3"
+func matchesLine(l openapi.Line, line string) bool {
+	return strconv.Itoa(l.No) == line || strings.EqualFold(l.Name, line)
+}
+
+//resolveStation returns the stop area id for a name or id
+func resolveStation(api openapi.OpenApi, q string) (int, string, error) {
+	if id, err := strconv.Atoi(q); err == nil {
+		return id, q, nil
 	}
 
-	PrintNearestStopAreas(result.NearestStopAreas)
+	result, err := api.QueryStation(q)
+	if err != nil {
+		return 0, "", err
+	}
+	for _, p := range result.StartPoints {
+		if p.Type == "STOP_AREA" {
+			return p.Id, p.Name, nil
+		}
+	}
+	return 0, "", fmt.Errorf("%q is not a station: %w", q, errNoResults)
 }
 
-func FindJourneys() {
-	/*
-		loc, _ := time.LoadLocation("Europe/Copenhagen")
-		t := openapi.Point{"Hjärnarp Kyrkan", 92156, "STOP_AREA", openapi.Coord{}}
-		f := openapi.Point{"Tygelsjö Laavägen", 80421, "STOP_AREA", openapi.Coord{6158063, 1322703}}
+func DepartureRows(lines []openapi.Line) *Rows {
+	rows := &Rows{Header: []string{"line", "towards", "time", "delay", "stop", "mode", "canceled"}}
+	for _, l := range lines {
+		rows.Add(l.Name, l.Towards, l.JourneyDateTime.Format(time.RFC3339),
+			strconv.Itoa(l.DelayMinutes()), l.StopPoint, l.TransportModeName,
+			strconv.FormatBool(l.IsCanceled()))
+	}
+	return rows
+}
 
-		result, _ := api.ResultsPage("next", f, t, time.Now().In(loc))
+func PrintDepartures(w io.Writer, lines []openapi.Line) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tTOWARDS\tTIME\tDELAY\tSTOP")
+	for _, l := range lines {
+		delay := ""
+		switch {
+		case l.IsCanceled():
+			delay = "canceled"
+		case l.DelayMinutes() != 0:
+			delay = fmt.Sprintf("%+d min", l.DelayMinutes())
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			l.Name, l.Towards, l.JourneyDateTime.Format("15:04"), delay, l.StopPoint)
+	}
+	tw.Flush()
+}
 
-		path, _ := api.JourneyPath(result.JourneyResultKey, 0)
-	*/
+func DeparturesCommand() *Command {
+	c := NewCommand("departures", "<name-or-id>", "Show the departure board of a station", 1)
+	c.Aliases = []string{"station"}
+	at := c.Flags.String("at", "", "time of the first departure, e.g. 15:04")
+	line := c.Flags.String("line", "", "only show line with this number or name")
+	mode := c.Flags.String("mode", "", "only show this mode (bus, train, tram, ferry) or line type (e.g. Pågatåg)")
+	limit := c.Flags.Int("limit", 0, "show at most this many departures")
+	c.Run = func(args []string) error {
+
+		t, err := parseTime(*at)
+		if err != nil {
+			return usageError{err}
+		}
+
+		api := openapi.NewOpenAPI()
+
+		id, name, err := resolveStation(api, args[0])
+		if err != nil {
+			return err
+		}
+
+		result, err := api.StationResult(id, t)
+		if err != nil {
+			return err
+		}
+		if result.StopAreaData.Name != "" {
+			name = result.StopAreaData.Name
+		}
+
+		var lines []openapi.Line
+		for _, l := range result.Lines {
+			if *line != "" && !matchesLine(l, *line) {
+				continue
+			}
+			if *mode != "" && !matchesMode(l, *mode) {
+				continue
+			}
+			lines = append(lines, l)
+		}
+		if len(lines) == 0 {
+			return errNoResults
+		}
+		if *limit > 0 && len(lines) > *limit {
+			lines = lines[:*limit]
+		}
+
+		result.Lines = lines
+		out := Output{JSON: result, GeoJSON: openapi.GeoJsonWriter{GeoJsonObject: result}, Rows: DepartureRows(lines), Text: func(w io.Writer) error {
+			fmt.Fprintf(w, "%s\n\n", name)
+			PrintDepartures(w, lines)
+			return nil
+		}}
+		return out.Write(FormatTable)
+	}
+	return c
 }
 
-func GetStationResult() {
+//stringsFlag is a flag that may be given many times
+type stringsFlag []string
 
-	res, _ := openapi.GetStationResult(80421, time.Now())
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
 
-	for _, r := range res.Lines {
-		fmt.Println(r)
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func ExportGTFSCommand() *Command {
+	c := NewCommand("export-gtfs", "<name-or-id>...", "Export departures and journeys as a zipped GTFS feed", -1)
+	out := c.Flags.String("out", "gtfs.zip", "file to write the feed to")
+	at := c.Flags.String("at", "", "time of the first departure, e.g. 15:04")
+	var journeys stringsFlag
+	c.Flags.Var(&journeys, "journey", "also add journeys between two places, as \"from:to\", may be repeated")
+	stopsFile := c.Flags.String("stops", "", "stop registry file, see package stops, to locate the stops along departures")
+	c.Run = func(args []string) error {
+
+		if len(args) == 0 && len(journeys) == 0 {
+			return usagef("expected at least one station or --journey")
+		}
+
+		t, err := parseTime(*at)
+		if err != nil {
+			return usageError{err}
+		}
+
+		api := openapi.NewOpenAPI()
+		feed := gtfs.NewFeed()
+
+		for _, q := range args {
+			id, _, err := resolveStation(api, q)
+			if err != nil {
+				return err
+			}
+			result, err := api.StationResult(id, t)
+			if err != nil {
+				return err
+			}
+			feed.AddStationResult(id, result)
+		}
+
+		for _, j := range journeys {
+			fromTo := strings.SplitN(j, ":", 2)
+			if len(fromTo) != 2 {
+				return usagef("expected --journey from:to, got %q", j)
+			}
+			points, err := api.QueryPage(fromTo[0], fromTo[1])
+			if err != nil {
+				return err
+			}
+			result, err := api.ResultsPage("next", points.StartPoints[0], points.EndPoints[0], t)
+			if err != nil {
+				return err
+			}
+			feed.AddJourneyResult(result)
+		}
+
+		if *stopsFile != "" {
+			reg, err := stops.LoadFile(*stopsFile)
+			if err != nil {
+				return err
+			}
+			feed.LocateStops(reg.Points())
+		}
+
+		err = feed.WriteFile(*out)
+		if errors.Is(err, gtfs.ErrNoTrips) {
+			hint := ""
+			if *stopsFile == "" {
+				hint = ", departure boards only locate their own stop, try --stops"
+			}
+			return fmt.Errorf("%w: %w%s", errNoResults, err, hint)
+		}
+		return err
 	}
+	return c
 }
 
 func main() {
 
-	if len(os.Args) < 2 {
-		fmt.Println("Try skanetrafiken <command>")
-		return
+	var err error
+	var args []string
+	if format, args, err = extractFormat(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "skanetrafiken:", err)
+		os.Exit(ExitUsage)
 	}
 
-	cmd := os.Args[1]
-
-	switch cmd {
-	case "station":
-		GetStationResult()
-	case "search":
-		SearchStation()
-	case "points":
-		SearchStartEndPoints()
-	case "nearest":
-		SearchNearestStations()
+	app := App{
+		SearchCommand(),
+		PointsCommand(),
+		NearestCommand(),
+		DeparturesCommand(),
+		JourneyCommand(),
+		PathCommand(),
+		ExportGTFSCommand(),
 	}
+	app = append(app, HelpCommand(&app), CompletionCommand(&app))
 
+	os.Exit(app.Execute(args))
 }
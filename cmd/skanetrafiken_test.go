@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Now().In(openapi.Stockholm)
+	today := func(hour, min int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, openapi.Stockholm)
+	}
+
+	tests := []struct {
+		s    string
+		want time.Time
+		err  bool
+	}{
+		{"2014-03-10T12:34:00+01:00", time.Date(2014, 3, 10, 11, 34, 0, 0, time.UTC), false},
+		{"2014-03-10 12:34", time.Date(2014, 3, 10, 12, 34, 0, 0, openapi.Stockholm), false},
+		{"2014-03-10T12:34", time.Date(2014, 3, 10, 12, 34, 0, 0, openapi.Stockholm), false},
+		{"2014-07-10 12:34", time.Date(2014, 7, 10, 10, 34, 0, 0, time.UTC), false},
+		{"15:04", today(15, 4), false},
+		{"noon", time.Time{}, true},
+		{"25:00", time.Time{}, true},
+		{"2014-03-10", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.s)
+		if (err != nil) != tt.err || (!tt.err && !got.Equal(tt.want)) {
+			t.Errorf("parseTime(%q): expected %v, got %v %v", tt.s, tt.want, got, err)
+		}
+	}
+
+	if got, err := parseTime(""); err != nil || got.Sub(now) > time.Minute || got.Location() != openapi.Stockholm {
+		t.Errorf("parseTime(\"\"): expected now, got %v %v", got, err)
+	}
+}
+
+func TestMatchesMode(t *testing.T) {
+
+	bus := openapi.Line{Name: "Stadsbuss 3", No: 3, TransportModeName: "Buss", LineTypeName: "Stadsbuss"}
+	pagatag := openapi.Line{Name: "Pågatåg", No: 1010, TransportModeName: "Tåg", LineTypeName: "Pågatåg"}
+	tram := openapi.Line{Name: "Spårvagn 1", No: 1, TransportModeName: "Spårvagn", LineTypeName: "Spårvagn"}
+
+	tests := []struct {
+		line openapi.Line
+		mode string
+		want bool
+	}{
+		{bus, "bus", true},
+		{bus, "BUS", true},
+		{bus, "Buss", true},
+		{bus, "stadsbuss", true},
+		{bus, "train", false},
+		{pagatag, "train", true},
+		{pagatag, "Pågatåg", true},
+		{pagatag, "bus", false},
+		{tram, "tram", true},
+		{tram, "ferry", false},
+	}
+	for _, tt := range tests {
+		if got := matchesMode(tt.line, tt.mode); got != tt.want {
+			t.Errorf("matchesMode(%s, %q): expected %v, got %v", tt.line.Name, tt.mode, tt.want, got)
+		}
+	}
+}
+
+func TestMatchesLine(t *testing.T) {
+
+	bus := openapi.Line{Name: "Stadsbuss 3", No: 3}
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"3", true},
+		{"Stadsbuss 3", true},
+		{"stadsbuss 3", true},
+		{"33", false},
+		{"Stadsbuss", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := matchesLine(bus, tt.line); got != tt.want {
+			t.Errorf("matchesLine(%q): expected %v, got %v", tt.line, tt.want, got)
+		}
+	}
+}
+
+//addressOnlyXML is a querystation response without stop areas
+const addressOnlyXML = `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>
+<GetStartEndPointResponse xmlns="http://www.etis.fskab.se/v1.0/ETISws"><GetStartEndPointResult>
+<Code>0</Code><Message />
+<StartPoints><Point><Id>1</Id><Name>Storgatan 1, Lund</Name><Type>ADDRESS</Type><X>6176609</X><Y>1335904</Y></Point></StartPoints>
+</GetStartEndPointResult></GetStartEndPointResponse>
+</soap:Body></soap:Envelope>`
+
+func TestResolveStation(t *testing.T) {
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(addressOnlyXML))
+	}))
+	defer srv.Close()
+	api := openapi.NewOpenAPI(openapi.WithBaseURL(srv.URL))
+
+	//Ids are used as they are
+	if id, name, err := resolveStation(api, "80000"); id != 80000 || name != "80000" || err != nil || requests != 0 {
+		t.Errorf("Expected 80000 without a request, got %d %q %v after %d requests", id, name, err, requests)
+	}
+
+	//Addresses are not stations, which is an empty result
+	_, _, err := resolveStation(api, "Storgatan 1")
+	if !errors.Is(err, errNoResults) || exitCode(err) != ExitNoResults {
+		t.Errorf("Expected no results, got %v", err)
+	}
+}
@@ -0,0 +1,478 @@
+/*
+Package gtfs builds a static GTFS feed from harvested Open API data.
+
+Add departure boards with AddStationResult and journeys with
+AddJourneyResult, then write the zipped feed with Write or WriteFile:
+
+	feed := gtfs.NewFeed()
+	res, _ := api.StationResult(80000, time.Now())
+	feed.AddStationResult(80000, res)
+	feed.WriteFile("skanetrafiken.zip")
+
+The Open API only gives coordinates for some stops, e.g. StopAreaData and
+the From and To of route links, not the stops along a departure's route.
+LocateStops adds positions from elsewhere, e.g. a stops.Registry. Stops
+whose position is never seen are left out of stops.txt, together with
+their stop times, and trips that end up with fewer than two stops are
+left out altogether. Write returns ErrNoTrips rather than an empty feed.
+*/
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//Agency defaults, used when the Open API does not name an operator
+const (
+	DefaultAgencyID   = "skanetrafiken"
+	DefaultAgencyName = "Skånetrafiken"
+	DefaultAgencyURL  = "https://www.skanetrafiken.se"
+	Timezone          = "Europe/Stockholm"
+)
+
+//ErrNoTrips is returned when writing a feed in which no trip has two located stops
+var ErrNoTrips = errors.New("no trips with two located stops")
+
+//GTFS route_type values
+const (
+	RouteTypeTram  = 0
+	RouteTypeRail  = 2
+	RouteTypeBus   = 3
+	RouteTypeFerry = 4
+)
+
+//routeTypes maps TransportModeName to route_type
+var routeTypes = map[string]int{
+	"Buss":     RouteTypeBus,
+	"Tåg":      RouteTypeRail,
+	"Spårvagn": RouteTypeTram,
+	"Färja":    RouteTypeFerry,
+}
+
+type agency struct {
+	id, name string
+}
+
+type stop struct {
+	id       int
+	name     string
+	lat, lon float64
+	located  bool
+}
+
+type route struct {
+	id, agencyID, shortName, longName string
+	routeType                         int
+}
+
+type stopTime struct {
+	stopID    int
+	arrival   time.Time
+	departure time.Time
+}
+
+/*
+trip is a run of a line. Its service date and id come from its earliest
+known departure, so they are only final once all results are added.
+*/
+type trip struct {
+	line              openapi.Line
+	routeID, headsign string
+	first             time.Time
+	stopTimes         map[int]*stopTime
+}
+
+//date returns the service date of the trip
+func (t *trip) date() time.Time {
+	return serviceDate(t.first)
+}
+
+//id returns the trip_id of the trip
+func (t *trip) id() string {
+	return TripID(t.line, t.first)
+}
+
+//includes tells if a departure at dep of the same run belongs to the trip
+func (t *trip) includes(dep time.Time) bool {
+	d := dep.Sub(t.first)
+	if d < 0 {
+		d = -d
+	}
+	return d < MaxTripDuration || serviceDate(dep).Equal(t.date())
+}
+
+//Feed collects agencies, stops, routes and trips for a GTFS feed
+type Feed struct {
+	agencies map[string]agency
+	stops    map[int]*stop
+	routes   map[string]route
+	trips    runs
+}
+
+//NewFeed creates an empty Feed
+func NewFeed() *Feed {
+	return &Feed{
+		agencies: make(map[string]agency),
+		stops:    make(map[int]*stop),
+		routes:   make(map[string]route),
+		trips:    make(runs),
+	}
+}
+
+//addStop records a stop, with its position if c is set
+func (f *Feed) addStop(id int, name string, c openapi.Coord) {
+	s, ok := f.stops[id]
+	if !ok {
+		s = &stop{id: id, name: name}
+		f.stops[id] = s
+	}
+	if s.name == "" {
+		s.name = name
+	}
+	if !s.located && c.X > 0 && c.Y > 0 {
+		pos := c.ToWGS84()
+		s.lat, s.lon = pos.Lat, pos.Lon
+		s.located = true
+	}
+}
+
+//LocateStops sets the position of the feed's stops among points, by id
+func (f *Feed) LocateStops(points []openapi.Point) {
+	for _, p := range points {
+		if s, ok := f.stops[p.Id]; ok {
+			f.addStop(s.id, p.Name, p.Coord)
+		}
+	}
+}
+
+//addRoute records the agency and route of a line and returns the route id
+func (f *Feed) addRoute(l openapi.Line) string {
+	a := agency{DefaultAgencyID, DefaultAgencyName}
+	if l.OperatorId != 0 {
+		a = agency{strconv.Itoa(l.OperatorId), l.OperatorName}
+	}
+	if a.name == "" {
+		a.name = DefaultAgencyName
+	}
+	f.agencies[a.id] = a
+
+	id := RouteID(l)
+	routeType, ok := routeTypes[l.TransportModeName]
+	if !ok {
+		routeType = RouteTypeBus
+	}
+	f.routes[id] = route{id, a.id, strconv.Itoa(l.No), l.Name, routeType}
+	return id
+}
+
+//ServiceDayStart is when a service day starts, earlier departures belong to the previous day
+const ServiceDayStart = 4 * time.Hour
+
+//MaxTripDuration is how far apart departures of the same run may be to belong to one trip
+const MaxTripDuration = 12 * time.Hour
+
+/*
+serviceDate returns the GTFS service day of t, i.e. noon minus 12h, in Stockholm.
+
+Departures before ServiceDayStart belong to the previous day. A trip gets
+the service date of its earliest known departure, for all of its stops.
+*/
+func serviceDate(t time.Time) time.Time {
+	t = t.In(openapi.Stockholm)
+	day := t.Day()
+	if time.Duration(t.Hour())*time.Hour < ServiceDayStart {
+		day--
+	}
+	return time.Date(t.Year(), t.Month(), day, 12, 0, 0, 0, openapi.Stockholm).Add(-12 * time.Hour)
+}
+
+//formatDate formats a service date as YYYYMMDD, which is not its wall-clock day on DST days
+func formatDate(date time.Time) string {
+	return date.Add(12 * time.Hour).Format("20060102")
+}
+
+//RouteID returns the route_id of a line
+func RouteID(l openapi.Line) string {
+	return fmt.Sprintf("%d-%d", l.LineTypeId, l.No)
+}
+
+/*
+TripID returns the trip_id of line l, whose earliest known departure is dep.
+
+Trips are identified by RunNo, or TrainNo, and the service date of dep.
+Lines with neither get an id from dep and Towards, which only matches
+between harvests from the same stop.
+*/
+func TripID(l openapi.Line, dep time.Time) string {
+	run := l.RunNo
+	if run == 0 {
+		run = l.TrainNo
+	}
+	if run == 0 {
+		return fmt.Sprintf("%s-%s-%s", RouteID(l), dep.In(openapi.Stockholm).Format("20060102-1504"), l.Towards)
+	}
+	return fmt.Sprintf("%s-%s-%d", RouteID(l), formatDate(serviceDate(dep)), run)
+}
+
+//runKey returns what identifies the trips of a run, apart from when they run
+func runKey(l openapi.Line, dep time.Time) string {
+	run := l.RunNo
+	if run == 0 {
+		run = l.TrainNo
+	}
+	if run == 0 {
+		return TripID(l, dep)
+	}
+	return fmt.Sprintf("%s-%d", RouteID(l), run)
+}
+
+//runs are trips grouped by runKey
+type runs map[string][]*trip
+
+//trip returns the trip of line l departing a stop at dep, creating it if needed
+func (r runs) trip(l openapi.Line, dep time.Time) *trip {
+	key := runKey(l, dep)
+	for _, t := range r[key] {
+		if t.includes(dep) {
+			if dep.Before(t.first) {
+				t.first = dep
+			}
+			return t
+		}
+	}
+	t := &trip{line: l, routeID: RouteID(l), headsign: l.Towards, first: dep, stopTimes: make(map[int]*stopTime)}
+	r[key] = append(r[key], t)
+	return t
+}
+
+//addTrip returns the trip of line l departing a stop at dep, creating it if needed
+func (f *Feed) addTrip(l openapi.Line, dep time.Time) *trip {
+	f.addRoute(l)
+	return f.trips.trip(l, dep)
+}
+
+//addStopTime records when the trip is at a stop, zero times are ignored
+func (t *trip) addStopTime(stopID int, arr, dep time.Time) {
+	st, ok := t.stopTimes[stopID]
+	if !ok {
+		st = &stopTime{stopID: stopID}
+		t.stopTimes[stopID] = st
+	}
+	if !arr.IsZero() {
+		st.arrival = arr
+	}
+	if !dep.IsZero() {
+		st.departure = dep
+	}
+}
+
+//addPointsOnRouteLink records the intermediate stops of a line
+func (f *Feed) addPointsOnRouteLink(t *trip, l openapi.Line) {
+	for _, p := range l.PointsOnRouteLink {
+		if p.ArrDateTime.IsZero() {
+			continue
+		}
+		f.addStop(p.Id, p.Name, openapi.Coord{})
+		t.addStopTime(p.Id, p.ArrDateTime.Time, time.Time{})
+	}
+}
+
+//AddStationResult adds the departures from the stop area stopID
+func (f *Feed) AddStationResult(stopID int, res openapi.GetDepartureArrivalResult) {
+	f.addStop(stopID, res.StopAreaData.Name, res.StopAreaData.Coord)
+
+	for _, l := range res.Lines {
+		if l.JourneyDateTime.IsZero() {
+			continue
+		}
+		t := f.addTrip(l, l.JourneyDateTime.Time)
+		t.addStopTime(stopID, time.Time{}, l.JourneyDateTime.Time)
+		f.addPointsOnRouteLink(t, l)
+	}
+}
+
+//AddJourneyResult adds the route links of all journeys, except walks
+func (f *Feed) AddJourneyResult(res openapi.GetJourneyResult) {
+	for _, j := range res.Journeys {
+		for _, r := range j.RouteLinks {
+			if (r.Line.No == 0 && r.Line.Name == "") || r.DepDateTime.IsZero() {
+				continue
+			}
+
+			f.addStop(r.From.Id, r.From.Name, r.From.Coord)
+			f.addStop(r.To.Id, r.To.Name, r.To.Coord)
+
+			t := f.addTrip(r.Line, r.DepDateTime.Time)
+			t.addStopTime(r.From.Id, time.Time{}, r.DepDateTime.Time)
+			f.addPointsOnRouteLink(t, r.Line)
+			t.addStopTime(r.To.Id, r.ArrDateTime.Time, time.Time{})
+		}
+	}
+}
+
+//gtfsTime formats t as HH:MM:SS since the service date, which may pass 24:00:00
+func gtfsTime(t, date time.Time) string {
+	d := t.Sub(date)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+//sortedStopTimes returns the stop times of located stops, in time order
+func (f *Feed) sortedStopTimes(t *trip) []*stopTime {
+	var sts []*stopTime
+	for _, st := range t.stopTimes {
+		if s := f.stops[st.stopID]; s == nil || !s.located {
+			continue
+		}
+		if st.arrival.IsZero() {
+			st.arrival = st.departure
+		}
+		if st.departure.IsZero() {
+			st.departure = st.arrival
+		}
+		sts = append(sts, st)
+	}
+	sort.Slice(sts, func(i, j int) bool {
+		return sts[i].arrival.Before(sts[j].arrival)
+	})
+	return sts
+}
+
+//table is a GTFS file, written as CSV
+type table struct {
+	name   string
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+//sortedKeys returns the keys of m in order
+func sortedKeys[K int | string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+//tables returns the contents of the feed's files, or ErrNoTrips
+func (f *Feed) tables() ([]*table, error) {
+
+	agencies := &table{name: "agency.txt", header: []string{"agency_id", "agency_name", "agency_url", "agency_timezone"}}
+	for _, id := range sortedKeys(f.agencies) {
+		a := f.agencies[id]
+		agencies.add(a.id, a.name, DefaultAgencyURL, Timezone)
+	}
+
+	routes := &table{name: "routes.txt", header: []string{"route_id", "agency_id", "route_short_name", "route_long_name", "route_type"}}
+	for _, id := range sortedKeys(f.routes) {
+		r := f.routes[id]
+		routes.add(r.id, r.agencyID, r.shortName, r.longName, strconv.Itoa(r.routeType))
+	}
+
+	trips := &table{name: "trips.txt", header: []string{"route_id", "service_id", "trip_id", "trip_headsign"}}
+	stopTimes := &table{name: "stop_times.txt", header: []string{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"}}
+	dates := make(map[string]bool)
+	used := make(map[int]bool)
+	byID := make(map[string]*trip)
+	for _, runs := range f.trips {
+		for _, t := range runs {
+			byID[t.id()] = t
+		}
+	}
+	for _, id := range sortedKeys(byID) {
+		t := byID[id]
+		sts := f.sortedStopTimes(t)
+		if len(sts) < 2 {
+			continue
+		}
+
+		date := t.date()
+		service := formatDate(date)
+		dates[service] = true
+		trips.add(t.routeID, service, id, t.headsign)
+		for n, st := range sts {
+			used[st.stopID] = true
+			stopTimes.add(id, gtfsTime(st.arrival, date), gtfsTime(st.departure, date),
+				strconv.Itoa(st.stopID), strconv.Itoa(n+1))
+		}
+	}
+
+	if len(trips.rows) == 0 {
+		return nil, ErrNoTrips
+	}
+
+	calendarDates := &table{name: "calendar_dates.txt", header: []string{"service_id", "date", "exception_type"}}
+	for _, d := range sortedKeys(dates) {
+		calendarDates.add(d, d, "1")
+	}
+
+	stops := &table{name: "stops.txt", header: []string{"stop_id", "stop_name", "stop_lat", "stop_lon"}}
+	for _, id := range sortedKeys(f.stops) {
+		s := f.stops[id]
+		if !s.located || !used[id] {
+			continue
+		}
+		stops.add(strconv.Itoa(s.id), s.name,
+			strconv.FormatFloat(s.lat, 'f', 6, 64), strconv.FormatFloat(s.lon, 'f', 6, 64))
+	}
+
+	return []*table{agencies, stops, routes, trips, stopTimes, calendarDates}, nil
+}
+
+//Write writes the feed as a zip archive
+func (f *Feed) Write(w io.Writer) error {
+	tables, err := f.tables()
+	if err != nil {
+		return err
+	}
+	return writeZip(w, tables)
+}
+
+//writeZip writes tables as a zip archive
+func writeZip(w io.Writer, tables []*table) error {
+	z := zip.NewWriter(w)
+	for _, t := range tables {
+		fw, err := z.Create(t.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		cw.Write(t.header)
+		cw.WriteAll(t.rows)
+		if err = cw.Error(); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+//WriteFile writes the feed as a zip archive to the named file, which is not created for ErrNoTrips
+func (f *Feed) WriteFile(name string) error {
+	tables, err := f.tables()
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err = writeZip(file, tables); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,295 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+func mustParseTime(s string) openapi.SkanetrafikenTime {
+	t, err := openapi.ParseSkanetrafikenTime(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+var oresundstag = openapi.Line{
+	Name:              "Öresundståg",
+	No:                1077,
+	RunNo:             1077,
+	LineTypeId:        1,
+	TransportModeName: "Tåg",
+	Towards:           "Helsingør",
+	OperatorId:        400,
+	OperatorName:      "Veolia Transport",
+	PointsOnRouteLink: []openapi.PointOnRouteLink{
+		{Id: 81216, Name: "Lund C", ArrDateTime: mustParseTime("2014-03-10T12:45:00")},
+	},
+}
+
+//readFeed returns the rows of each file in the zipped feed
+func readFeed(t *testing.T, f *Feed) map[string][][]string {
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][][]string)
+	for _, zf := range z.File {
+		r, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[zf.Name] = rows
+	}
+	return files
+}
+
+func TestFeed(t *testing.T) {
+
+	f := NewFeed()
+
+	f.AddJourneyResult(openapi.GetJourneyResult{Journeys: []openapi.Journey{{
+		RouteLinks: []openapi.RouteLink{{
+			DepDateTime: mustParseTime("2014-03-10T12:34:00"),
+			ArrDateTime: mustParseTime("2014-03-10T13:01:00"),
+			From:        openapi.Point{Name: "Malmö C", Id: 80000, Coord: openapi.Coord{X: 6167946, Y: 1323245}},
+			To:          openapi.Point{Name: "Landskrona", Id: 82000, Coord: openapi.Coord{X: 6197478, Y: 1311283}},
+			Line:        oresundstag,
+		}},
+	}}})
+
+	//The same trip seen from the departure board at Lund C
+	lund := oresundstag
+	lund.PointsOnRouteLink = nil
+	lund.JourneyDateTime = mustParseTime("2014-03-10T12:46:00")
+	f.AddStationResult(81216, openapi.GetDepartureArrivalResult{
+		Lines:        []openapi.Line{lund},
+		StopAreaData: openapi.StopAreaData{Name: "Lund C", Coord: openapi.Coord{X: 6176609, Y: 1335904}},
+	})
+
+	files := readFeed(t, f)
+
+	for _, name := range []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt", "calendar_dates.txt"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Missing %s", name)
+		}
+	}
+
+	if a := files["agency.txt"]; len(a) != 2 || a[1][0] != "400" || a[1][3] != "Europe/Stockholm" {
+		t.Errorf("Unexpected agency.txt %v", a)
+	}
+	if r := files["routes.txt"]; len(r) != 2 || r[1][0] != "1-1077" || r[1][4] != "2" {
+		t.Errorf("Unexpected routes.txt %v", r)
+	}
+	if s := files["stops.txt"]; len(s) != 4 || s[1][0] != "80000" || s[1][2] != "55.608777" {
+		t.Errorf("Unexpected stops.txt %v", s)
+	}
+	if tr := files["trips.txt"]; len(tr) != 2 || tr[1][2] != "1-1077-20140310-1077" {
+		t.Errorf("Unexpected trips.txt %v", tr)
+	}
+
+	want := [][]string{
+		{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"},
+		{"1-1077-20140310-1077", "12:34:00", "12:34:00", "80000", "1"},
+		{"1-1077-20140310-1077", "12:45:00", "12:46:00", "81216", "2"},
+		{"1-1077-20140310-1077", "13:01:00", "13:01:00", "82000", "3"},
+	}
+	st := files["stop_times.txt"]
+	if len(st) != len(want) {
+		t.Fatalf("Unexpected stop_times.txt %v", st)
+	}
+	for n := range want {
+		for i := range want[n] {
+			if st[n][i] != want[n][i] {
+				t.Errorf("stop_times.txt row %d: expected %v, got %v", n, want[n], st[n])
+				break
+			}
+		}
+	}
+}
+
+func TestFeedDropsUnlocatedStops(t *testing.T) {
+
+	f := NewFeed()
+
+	l := oresundstag
+	l.JourneyDateTime = mustParseTime("2014-03-10T12:34:00")
+	f.AddStationResult(80000, openapi.GetDepartureArrivalResult{
+		Lines:        []openapi.Line{l},
+		StopAreaData: openapi.StopAreaData{Name: "Malmö C", Coord: openapi.Coord{X: 6167946, Y: 1323245}},
+	})
+
+	//The only trip has one located stop, so there is nothing to write
+	if err := f.Write(&bytes.Buffer{}); !errors.Is(err, ErrNoTrips) {
+		t.Errorf("Expected ErrNoTrips, got %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "gtfs.zip")
+	if err := f.WriteFile(name); !errors.Is(err, ErrNoTrips) {
+		t.Errorf("Expected ErrNoTrips, got %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Expected no file, got %v", err)
+	}
+
+	//Lund C is located from elsewhere, e.g. a stops registry
+	f.LocateStops([]openapi.Point{
+		{Name: "Lund C", Id: 81216, Type: "STOP_AREA", Coord: openapi.Coord{X: 6176609, Y: 1335904}},
+		{Name: "Helsingborg C", Id: 83241, Type: "STOP_AREA", Coord: openapi.Coord{X: 6233389, Y: 1307424}},
+	})
+	files := readFeed(t, f)
+	if len(files["trips.txt"]) != 2 || len(files["stops.txt"]) != 3 || len(files["stop_times.txt"]) != 3 {
+		t.Errorf("Expected the trip with both stops, got %v %v", files["trips.txt"], files["stops.txt"])
+	}
+}
+
+func TestFeedAgencyName(t *testing.T) {
+
+	f := NewFeed()
+
+	//agency_name is required, also when the operator has no name
+	unnamed := oresundstag
+	unnamed.OperatorName = ""
+	f.addRoute(unnamed)
+	f.addRoute(openapi.Line{Name: "Buss 1", No: 1, LineTypeId: 4})
+
+	for id, want := range map[string]string{"400": DefaultAgencyName, DefaultAgencyID: DefaultAgencyName} {
+		if a := f.agencies[id]; a.name != want {
+			t.Errorf("Agency %s: expected %q, got %q", id, want, a.name)
+		}
+	}
+}
+
+func TestGTFSTimeAfterMidnight(t *testing.T) {
+
+	dep := mustParseTime("2014-03-11T00:15:00")
+	date := serviceDate(mustParseTime("2014-03-10T23:50:00").Time)
+
+	if s := gtfsTime(dep.Time, date); s != "24:15:00" {
+		t.Errorf("Expected 24:15:00, got %s", s)
+	}
+}
+
+func TestFeedTripAfterMidnight(t *testing.T) {
+
+	f := NewFeed()
+
+	//A late run harvested from Malmö C before, and Lund C after, midnight
+	malmo := oresundstag
+	malmo.PointsOnRouteLink = nil
+	malmo.JourneyDateTime = mustParseTime("2014-03-10T23:50:00")
+	f.AddStationResult(80000, openapi.GetDepartureArrivalResult{
+		Lines:        []openapi.Line{malmo},
+		StopAreaData: openapi.StopAreaData{Name: "Malmö C", Coord: openapi.Coord{X: 6167946, Y: 1323245}},
+	})
+
+	lund := malmo
+	lund.JourneyDateTime = mustParseTime("2014-03-11T00:02:00")
+	f.AddStationResult(81216, openapi.GetDepartureArrivalResult{
+		Lines:        []openapi.Line{lund},
+		StopAreaData: openapi.StopAreaData{Name: "Lund C", Coord: openapi.Coord{X: 6176609, Y: 1335904}},
+	})
+
+	files := readFeed(t, f)
+
+	if tr := files["trips.txt"]; len(tr) != 2 || tr[1][2] != "1-1077-20140310-1077" {
+		t.Errorf("Expected one trip on 2014-03-10, got %v", tr)
+	}
+	if st := files["stop_times.txt"]; len(st) != 3 || st[2][1] != "24:02:00" {
+		t.Errorf("Unexpected stop_times.txt %v", st)
+	}
+	if c := files["calendar_dates.txt"]; len(c) != 2 || c[1][1] != "20140310" {
+		t.Errorf("Unexpected calendar_dates.txt %v", c)
+	}
+}
+
+func TestFeedTripAcrossServiceDayStart(t *testing.T) {
+
+	//A night run seen at Malmö C before, and at Lund C after, 04:00, in either order
+	malmo := oresundstag
+	malmo.PointsOnRouteLink = nil
+	malmo.JourneyDateTime = mustParseTime("2014-03-11T03:50:00")
+	lund := malmo
+	lund.JourneyDateTime = mustParseTime("2014-03-11T04:05:00")
+
+	boards := []struct {
+		stopID int
+		line   openapi.Line
+		data   openapi.StopAreaData
+	}{
+		{80000, malmo, openapi.StopAreaData{Name: "Malmö C", Coord: openapi.Coord{X: 6167946, Y: 1323245}}},
+		{81216, lund, openapi.StopAreaData{Name: "Lund C", Coord: openapi.Coord{X: 6176609, Y: 1335904}}},
+	}
+	for _, order := range [][]int{{0, 1}, {1, 0}} {
+		f := NewFeed()
+		for _, n := range order {
+			b := boards[n]
+			f.AddStationResult(b.stopID, openapi.GetDepartureArrivalResult{Lines: []openapi.Line{b.line}, StopAreaData: b.data})
+		}
+
+		files := readFeed(t, f)
+		if tr := files["trips.txt"]; len(tr) != 2 || tr[1][2] != "1-1077-20140310-1077" {
+			t.Errorf("Order %v: expected one trip on 2014-03-10, got %v", order, tr)
+		}
+		if st := files["stop_times.txt"]; len(st) != 3 || st[1][1] != "27:50:00" || st[2][1] != "28:05:00" {
+			t.Errorf("Order %v: unexpected stop_times.txt %v", order, st)
+		}
+	}
+}
+
+func TestFeedRunOnTwoDays(t *testing.T) {
+
+	f := NewFeed()
+
+	//The same run on two days is two trips
+	for _, dep := range []string{"2014-03-10T12:34:00", "2014-03-11T12:34:00"} {
+		l := oresundstag
+		l.JourneyDateTime = mustParseTime(dep)
+		l.PointsOnRouteLink = []openapi.PointOnRouteLink{{Id: 81216, Name: "Lund C", ArrDateTime: mustParseTime(dep[:11] + "12:45:00")}}
+		f.AddStationResult(80000, openapi.GetDepartureArrivalResult{
+			Lines:        []openapi.Line{l},
+			StopAreaData: openapi.StopAreaData{Name: "Malmö C", Coord: openapi.Coord{X: 6167946, Y: 1323245}},
+		})
+	}
+	f.addStop(81216, "Lund C", openapi.Coord{X: 6176609, Y: 1335904})
+
+	tr := readFeed(t, f)["trips.txt"]
+	if len(tr) != 3 || tr[1][2] != "1-1077-20140310-1077" || tr[2][2] != "1-1077-20140311-1077" {
+		t.Errorf("Expected a trip on each day, got %v", tr)
+	}
+}
+
+func TestServiceDate(t *testing.T) {
+
+	tests := []struct {
+		t, want string
+	}{
+		{"2014-03-10T12:00:00", "20140310"},
+		{"2014-03-10T23:59:00", "20140310"},
+		{"2014-03-11T03:59:00", "20140310"},
+		{"2014-03-11T04:00:00", "20140311"},
+		{"2014-03-01T01:00:00", "20140228"},
+		{"2014-03-30T04:30:00", "20140330"},
+	}
+	for _, tt := range tests {
+		if d := formatDate(serviceDate(mustParseTime(tt.t).Time)); d != tt.want {
+			t.Errorf("serviceDate(%s): expected %s, got %s", tt.t, tt.want, d)
+		}
+	}
+}
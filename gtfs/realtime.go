@@ -0,0 +1,226 @@
+package gtfs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//ContentTypeProtobuf is the content type of GTFS-Realtime feeds
+const ContentTypeProtobuf = "application/x-protobuf"
+
+//Field numbers and enum values from gtfs-realtime.proto
+const (
+	feedMessageHeader = 1
+	feedMessageEntity = 2
+
+	feedHeaderVersion        = 1
+	feedHeaderIncrementality = 2
+	feedHeaderTimestamp      = 3
+
+	feedEntityID         = 1
+	feedEntityTripUpdate = 3
+
+	tripUpdateTrip           = 1
+	tripUpdateStopTimeUpdate = 2
+	tripUpdateTimestamp      = 4
+
+	tripDescriptorTripID               = 1
+	tripDescriptorStartDate            = 3
+	tripDescriptorScheduleRelationship = 4
+	tripDescriptorRouteID              = 5
+
+	stopTimeUpdateDeparture = 3
+	stopTimeUpdateStopID    = 4
+
+	stopTimeEventDelay = 1
+	stopTimeEventTime  = 2
+
+	incrementalityFullDataset = 0
+	tripScheduled             = 0
+	tripCanceled              = 3
+)
+
+//departureUpdate is a real-time departure of a trip from a stop
+type departureUpdate struct {
+	line      openapi.Line
+	stopID    int
+	scheduled time.Time
+	delay     int
+	canceled  bool
+	seen      time.Time
+}
+
+/*
+TripUpdates generates a GTFS-Realtime feed of TripUpdates from polled
+departure boards. Trip and route ids match those of Feed, as trips get
+the service date of their earliest departure on the polled boards.
+
+Each call to AddStationResult replaces the updates previously added for
+that stop, so it can be fed by a poller, see Poll. TripUpdates is safe for
+concurrent use, and serves the feed as an http.Handler.
+*/
+type TripUpdates struct {
+	mu     sync.Mutex
+	byStop map[int][]departureUpdate
+	now    func() time.Time
+}
+
+//NewTripUpdates creates an empty TripUpdates
+func NewTripUpdates() *TripUpdates {
+	return &TripUpdates{byStop: make(map[int][]departureUpdate), now: time.Now}
+}
+
+//AddStationResult sets the real-time departures from the stop area stopID
+func (u *TripUpdates) AddStationResult(stopID int, res openapi.GetDepartureArrivalResult) {
+	now := u.now()
+
+	var updates []departureUpdate
+	for _, l := range res.Lines {
+		if l.JourneyDateTime.IsZero() {
+			continue
+		}
+		updates = append(updates, departureUpdate{
+			line:      l,
+			stopID:    stopID,
+			scheduled: l.JourneyDateTime.Time,
+			delay:     l.RealTime.DepTimeDeviation,
+			canceled:  l.RealTime.Canceled,
+			seen:      now,
+		})
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.byStop[stopID] = updates
+}
+
+//FeedMessage returns the encoded GTFS-Realtime FeedMessage
+func (u *TripUpdates) FeedMessage() []byte {
+	u.mu.Lock()
+	var all []departureUpdate
+	for _, updates := range u.byStop {
+		all = append(all, updates...)
+	}
+	now := u.now()
+	u.mu.Unlock()
+
+	//Grouped like in Feed, earliest first so that each trip starts with its first departure
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].scheduled.Before(all[j].scheduled)
+	})
+	trips := make(runs)
+	byTrip := make(map[string][]departureUpdate)
+	byID := make(map[string]*trip)
+	for _, d := range all {
+		t := trips.trip(d.line, d.scheduled)
+		byTrip[t.id()] = append(byTrip[t.id()], d)
+		byID[t.id()] = t
+	}
+
+	var header []byte
+	header = appendString(header, feedHeaderVersion, "2.0")
+	header = appendUint(header, feedHeaderIncrementality, incrementalityFullDataset)
+	header = appendUint(header, feedHeaderTimestamp, uint64(now.Unix()))
+
+	var msg []byte
+	msg = appendBytes(msg, feedMessageHeader, header)
+	for _, id := range sortedKeys(byTrip) {
+		msg = appendBytes(msg, feedMessageEntity, encodeEntity(byID[id], byTrip[id]))
+	}
+	return msg
+}
+
+//encodeEntity encodes a FeedEntity with the TripUpdate of trip t
+func encodeEntity(t *trip, updates []departureUpdate) []byte {
+	tripID := t.id()
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].scheduled.Before(updates[j].scheduled)
+	})
+
+	canceled := false
+	var seen time.Time
+	for _, d := range updates {
+		canceled = canceled || d.canceled
+		if d.seen.After(seen) {
+			seen = d.seen
+		}
+	}
+
+	var trip []byte
+	trip = appendString(trip, tripDescriptorTripID, tripID)
+	trip = appendString(trip, tripDescriptorStartDate, formatDate(t.date()))
+	if canceled {
+		trip = appendUint(trip, tripDescriptorScheduleRelationship, tripCanceled)
+	} else {
+		trip = appendUint(trip, tripDescriptorScheduleRelationship, tripScheduled)
+	}
+	trip = appendString(trip, tripDescriptorRouteID, t.routeID)
+
+	var tu []byte
+	tu = appendBytes(tu, tripUpdateTrip, trip)
+	if !canceled {
+		for _, d := range updates {
+			var event []byte
+			event = appendInt(event, stopTimeEventDelay, int64(d.delay*60))
+			event = appendInt(event, stopTimeEventTime, d.scheduled.Add(time.Duration(d.delay)*time.Minute).Unix())
+
+			var stu []byte
+			stu = appendBytes(stu, stopTimeUpdateDeparture, event)
+			stu = appendString(stu, stopTimeUpdateStopID, strconv.Itoa(d.stopID))
+			tu = appendBytes(tu, tripUpdateStopTimeUpdate, stu)
+		}
+	}
+	tu = appendUint(tu, tripUpdateTimestamp, uint64(seen.Unix()))
+
+	var entity []byte
+	entity = appendString(entity, feedEntityID, tripID)
+	entity = appendBytes(entity, feedEntityTripUpdate, tu)
+	return entity
+}
+
+//ServeHTTP serves the feed
+func (u *TripUpdates) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentTypeProtobuf)
+	w.Write(u.FeedMessage())
+}
+
+//WriteFile writes the feed to the named file
+func (u *TripUpdates) WriteFile(name string) error {
+	return os.WriteFile(name, u.FeedMessage(), 0644)
+}
+
+/*
+Poll fetches the departure boards of stopIDs every interval, until ctx is
+done. Failed requests keep the previous updates of the stop, and are
+reported to onError if it is not nil. Stops without departures are cleared.
+*/
+func (u *TripUpdates) Poll(ctx context.Context, api openapi.OpenApi, interval time.Duration, onError func(error), stopIDs ...int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, id := range stopIDs {
+			res, err := api.StationResultContext(ctx, id, u.now())
+			switch {
+			case err == nil, errors.Is(err, openapi.ErrNoDeparturesFound):
+				u.AddStationResult(id, res)
+			case onError != nil:
+				onError(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
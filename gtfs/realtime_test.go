@@ -0,0 +1,191 @@
+package gtfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//message is a decoded protocol buffer message, values are uint64 or []byte
+type message map[int][]interface{}
+
+func decodeVarint(b []byte) (uint64, []byte, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if len(b) == 0 {
+			return 0, nil, errors.New("truncated varint")
+		}
+		c := b[0]
+		b = b[1:]
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, b, nil
+		}
+	}
+	return 0, nil, errors.New("varint overflow")
+}
+
+func decodeMessage(t *testing.T, b []byte) message {
+	m := make(message)
+	for len(b) > 0 {
+		tag, rest, err := decodeVarint(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		field, wireType := int(tag>>3), int(tag&7)
+
+		v, rest, err := decodeVarint(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch wireType {
+		case wireVarint:
+			m[field] = append(m[field], v)
+		case wireBytes:
+			m[field] = append(m[field], rest[:v])
+			rest = rest[v:]
+		default:
+			t.Fatalf("Unexpected wire type %d", wireType)
+		}
+		b = rest
+	}
+	return m
+}
+
+func (m message) sub(t *testing.T, field, n int) message {
+	return decodeMessage(t, m[field][n].([]byte))
+}
+
+func (m message) str(field int) string {
+	return string(m[field][0].([]byte))
+}
+
+func (m message) int(field int) int64 {
+	return int64(m[field][0].(uint64))
+}
+
+func TestTripUpdates(t *testing.T) {
+
+	u := NewTripUpdates()
+	u.now = func() time.Time { return time.Unix(1394451000, 0) }
+
+	delayed := oresundstag
+	delayed.JourneyDateTime = mustParseTime("2014-03-10T12:34:00")
+	delayed.RealTime.DepTimeDeviation = 3
+
+	early := oresundstag
+	early.No, early.RunNo = 3, 42
+	early.JourneyDateTime = mustParseTime("2014-03-10T12:40:00")
+	early.RealTime.DepTimeDeviation = -1
+
+	canceled := oresundstag
+	canceled.No, canceled.RunNo = 5, 7
+	canceled.JourneyDateTime = mustParseTime("2014-03-10T12:50:00")
+	canceled.RealTime.Canceled = true
+
+	u.AddStationResult(80000, openapi.GetDepartureArrivalResult{Lines: []openapi.Line{delayed, early, canceled}})
+
+	feed := decodeMessage(t, u.FeedMessage())
+
+	header := feed.sub(t, feedMessageHeader, 0)
+	if header.str(feedHeaderVersion) != "2.0" || header.int(feedHeaderTimestamp) != 1394451000 {
+		t.Errorf("Unexpected header %v", header)
+	}
+
+	if len(feed[feedMessageEntity]) != 3 {
+		t.Fatalf("Expected 3 entities, got %d", len(feed[feedMessageEntity]))
+	}
+
+	//Entities are sorted on trip id
+	entity := feed.sub(t, feedMessageEntity, 0)
+	if entity.str(feedEntityID) != "1-1077-20140310-1077" {
+		t.Errorf("Unexpected entity id %s", entity.str(feedEntityID))
+	}
+	tu := entity.sub(t, feedEntityTripUpdate, 0)
+	trip := tu.sub(t, tripUpdateTrip, 0)
+	if trip.str(tripDescriptorRouteID) != "1-1077" || trip.str(tripDescriptorStartDate) != "20140310" ||
+		trip.int(tripDescriptorScheduleRelationship) != tripScheduled {
+		t.Errorf("Unexpected trip %v", trip)
+	}
+	stu := tu.sub(t, tripUpdateStopTimeUpdate, 0)
+	dep := stu.sub(t, stopTimeUpdateDeparture, 0)
+	if stu.str(stopTimeUpdateStopID) != "80000" || dep.int(stopTimeEventDelay) != 180 ||
+		dep.int(stopTimeEventTime) != mustParseTime("2014-03-10T12:37:00").Unix() {
+		t.Errorf("Unexpected stop time update %v %v", stu, dep)
+	}
+
+	//Negative delays are encoded as sign-extended varints
+	dep = feed.sub(t, feedMessageEntity, 1).sub(t, feedEntityTripUpdate, 0).
+		sub(t, tripUpdateStopTimeUpdate, 0).sub(t, stopTimeUpdateDeparture, 0)
+	if dep.int(stopTimeEventDelay) != -60 {
+		t.Errorf("Expected delay -60, got %d", dep.int(stopTimeEventDelay))
+	}
+
+	tu = feed.sub(t, feedMessageEntity, 2).sub(t, feedEntityTripUpdate, 0)
+	if tu.sub(t, tripUpdateTrip, 0).int(tripDescriptorScheduleRelationship) != tripCanceled ||
+		len(tu[tripUpdateStopTimeUpdate]) != 0 {
+		t.Errorf("Expected canceled trip without stop time updates, got %v", tu)
+	}
+
+	//Polling the stop again replaces its updates
+	u.AddStationResult(80000, openapi.GetDepartureArrivalResult{})
+	if feed = decodeMessage(t, u.FeedMessage()); len(feed[feedMessageEntity]) != 0 {
+		t.Errorf("Expected no entities, got %d", len(feed[feedMessageEntity]))
+	}
+}
+
+func TestTripUpdatesAfterMidnight(t *testing.T) {
+
+	u := NewTripUpdates()
+
+	//A late run seen at Malmö C before, and at Lund C after, midnight
+	malmo := oresundstag
+	malmo.JourneyDateTime = mustParseTime("2014-03-10T23:50:00")
+	malmo.RealTime.DepTimeDeviation = 2
+	u.AddStationResult(80000, openapi.GetDepartureArrivalResult{Lines: []openapi.Line{malmo}})
+
+	lund := oresundstag
+	lund.JourneyDateTime = mustParseTime("2014-03-11T00:02:00")
+	lund.RealTime.DepTimeDeviation = 2
+	u.AddStationResult(81216, openapi.GetDepartureArrivalResult{Lines: []openapi.Line{lund}})
+
+	feed := decodeMessage(t, u.FeedMessage())
+	if len(feed[feedMessageEntity]) != 1 {
+		t.Fatalf("Expected 1 entity, got %d", len(feed[feedMessageEntity]))
+	}
+
+	entity := feed.sub(t, feedMessageEntity, 0)
+	if entity.str(feedEntityID) != "1-1077-20140310-1077" {
+		t.Errorf("Unexpected entity id %s", entity.str(feedEntityID))
+	}
+	tu := entity.sub(t, feedEntityTripUpdate, 0)
+	if d := tu.sub(t, tripUpdateTrip, 0).str(tripDescriptorStartDate); d != "20140310" {
+		t.Errorf("Expected start date 20140310, got %s", d)
+	}
+	if len(tu[tripUpdateStopTimeUpdate]) != 2 {
+		t.Errorf("Expected 2 stop time updates, got %d", len(tu[tripUpdateStopTimeUpdate]))
+	}
+}
+
+func TestTripUpdatesAcrossServiceDayStart(t *testing.T) {
+
+	u := NewTripUpdates()
+
+	//A night run seen at Malmö C before, and at Lund C after, 04:00
+	malmo := oresundstag
+	malmo.JourneyDateTime = mustParseTime("2014-03-11T03:50:00")
+	lund := oresundstag
+	lund.JourneyDateTime = mustParseTime("2014-03-11T04:05:00")
+	u.AddStationResult(81216, openapi.GetDepartureArrivalResult{Lines: []openapi.Line{lund}})
+	u.AddStationResult(80000, openapi.GetDepartureArrivalResult{Lines: []openapi.Line{malmo}})
+
+	feed := decodeMessage(t, u.FeedMessage())
+	if len(feed[feedMessageEntity]) != 1 {
+		t.Fatalf("Expected 1 entity, got %d", len(feed[feedMessageEntity]))
+	}
+	if id := feed.sub(t, feedMessageEntity, 0).str(feedEntityID); id != "1-1077-20140310-1077" {
+		t.Errorf("Expected the trip id of the static feed, got %s", id)
+	}
+}
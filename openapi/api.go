@@ -19,6 +19,7 @@ for n, point := range res.StartPoints:
 package openapi
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -42,7 +43,18 @@ const (
 )
 
 type OpenApi struct {
-	client *http.Client
+	client    *http.Client
+	baseURL   string
+	userAgent string
+	header    http.Header
+	retry     *RetryPolicy
+
+	cache      Cache
+	cacheTTL   map[string]time.Duration
+	cacheStats *cacheCounters
+
+	flight *flightGroup
+	limits *rateLimits
 }
 
 var DefaultClient = &OpenApi{}
@@ -54,9 +66,27 @@ func (api OpenApi) transport() *http.Client {
 	return new(http.Client)
 }
 
-//NewOpenAPI creates a new instance of the OpenAPI
-func NewOpenAPI() OpenApi {
-	api := OpenApi{new(http.Client)}
+//endpointURL returns the full URL of the endpoint, relative to the instance's base URL
+func (api OpenApi) endpointURL(endpoint string) string {
+	base := BaseURL
+	if api.baseURL != "" {
+		base = api.baseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/" + endpoint
+}
+
+/*
+NewOpenAPI creates a new instance of the OpenAPI.
+
+Options override the defaults, e.g. to target a local stand-in:
+
+	api := NewOpenAPI(WithBaseURL("http://localhost:8080/"), WithUserAgent("myapp/1.0"))
+*/
+func NewOpenAPI(opts ...Option) OpenApi {
+	api := OpenApi{client: new(http.Client)}
+	for _, opt := range opts {
+		opt(&api)
+	}
 	return api
 }
 
@@ -74,7 +104,7 @@ type PointOnRouteLink struct {
 	Id               int
 	Name             string
 	StopPoint        string
-	ArrDateTime      string
+	ArrDateTime      SkanetrafikenTime
 	ArrIsTimingPoint bool
 }
 
@@ -95,7 +125,8 @@ type Line struct {
 	LineTypeId        int
 	LineTypeName      string
 	TransportModeId   int
-	JourneyDateTime   string
+	JourneyDateTime   SkanetrafikenTime
+	StopPoint         string
 	TransportModeName string
 	Towards           string
 	TrainNo           int
@@ -112,6 +143,12 @@ const (
 	UNKNOWN
 )
 
+//Values of selDirection in ResultsPageDirection
+const (
+	DEPARTURE = iota
+	ARRIVAL
+)
+
 //Coord is RT90 coordinates
 type Coord struct {
 	X float64
@@ -154,8 +191,8 @@ func NewPointFromURIParameter(s string) (*Point, error) {
 
 type RouteLink struct {
 	RouteLinkKey string
-	DepDateTime  string
-	ArrDateTime  string
+	DepDateTime  SkanetrafikenTime
+	ArrDateTime  SkanetrafikenTime
 	From         Point
 	To           Point
 	RealTime     RealTimeInfo
@@ -164,8 +201,8 @@ type RouteLink struct {
 
 type Journey struct {
 	SequenceNo  int
-	DepDateTime string
-	ArrDateTime string
+	DepDateTime SkanetrafikenTime
+	ArrDateTime SkanetrafikenTime
 	DepWalkDist int
 	ArrWalkDist int
 	NoOfChanges int
@@ -276,81 +313,224 @@ type SOAPBody struct {
 	GetDepartureArrivalResponse GetDepartureArrivalResponse
 }
 
+//failed tells if any of the responses carries an error Status
+func (b SOAPBody) failed() bool {
+	return b.GetStartEndPointResponse.GetStartEndPointResult.Code != 0 ||
+		b.GetJourneyResponse.GetJourneyResult.Code != 0 ||
+		b.GetJourneyPathResponse.GetJourneyPathResult.Code != 0 ||
+		b.GetNearestStopAreaResponse.GetNearestStopAreaResult.Code != 0 ||
+		b.GetDepartureArrivalResponse.GetDepartureArrivalResult.Code != 0
+}
+
 type SOAPEnvelope struct {
 	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
 	Body    SOAPBody `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
 }
 
-//get loads SOAP Envelope from the endpoint and stores it in the body parameter.
-func (api OpenApi) get(endpoint string, params url.Values, body interface{}) error {
+//get loads SOAP Envelope from the endpoint and stores it in the body parameter,
+//using the instance's Cache, request coalescing and RetryPolicy.
+func (api OpenApi) get(ctx context.Context, endpoint string, params url.Values, body *SOAPEnvelope) error {
 
-	var err error
+	key := endpoint + "?" + params.Encode()
+	ttl := api.cacheTTL[endpoint]
+
+	if api.cache != nil && ttl > 0 {
+		if data, ok := api.cache.Get(key); ok {
+			api.cacheStats.hits.Add(1)
+			if err := xml.Unmarshal(data, body); err != nil {
+				return &APIError{Endpoint: endpoint, Err: fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)}
+			}
+			return nil
+		}
+		api.cacheStats.misses.Add(1)
+	}
 
-	url := BaseURL + endpoint + "?" + params.Encode()
+	fetch := func(ctx context.Context) (data []byte, err error) {
+		if api.retry == nil {
+			return api.getOnce(ctx, endpoint, params)
+		}
+		err = api.retry.do(ctx, endpoint, func() error {
+			data, err = api.getOnce(ctx, endpoint, params)
+			return err
+		})
+		return data, err
+	}
 
-	res, err := api.transport().Get(url)
+	var data []byte
+	var err error
+	if api.flight != nil {
+		data, err = api.flight.do(ctx, key, fetch)
+	} else {
+		data, err = fetch(ctx)
+	}
 	if err != nil {
 		return err
 	}
 
-	data, err := ioutil.ReadAll(res.Body)
-	defer res.Body.Close()
+	if err = xml.Unmarshal(data, body); err != nil {
+		return &APIError{Endpoint: endpoint, Err: fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)}
+	}
+
+	if api.cache != nil && ttl > 0 && !body.Body.failed() {
+		api.cache.Set(key, data, ttl)
+	}
+
+	return nil
+}
+
+//getOnce makes a single request to the endpoint and returns the response body
+func (api OpenApi) getOnce(ctx context.Context, endpoint string, params url.Values) ([]byte, error) {
+
+	var err error
+
+	if api.limits != nil {
+		if err = api.limits.wait(ctx, endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	url := api.endpointURL(endpoint) + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for key, values := range api.header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	if api.userAgent != "" {
+		req.Header.Set("User-Agent", api.userAgent)
+	}
+
+	res, err := api.transport().Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &APIError{Endpoint: endpoint, Err: fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, httpError(endpoint, res)
 	}
 
-	return xml.Unmarshal([]byte(data), &body)
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, &APIError{Endpoint: endpoint, Err: fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)}
+	}
 
+	return data, nil
 }
 
 //QueryStation returns stations with matching names
 func (api OpenApi) QueryStation(inpPointFr string) (res GetStartEndPointResult, err error) {
+	return api.QueryStationContext(context.Background(), inpPointFr)
+}
+
+//QueryStationContext is like QueryStation but carries ctx to the upstream request
+func (api OpenApi) QueryStationContext(ctx context.Context, inpPointFr string) (res GetStartEndPointResult, err error) {
 
 	params := url.Values{}
 	params.Set("inpPointFr", inpPointFr)
 
 	soap := SOAPEnvelope{}
-	if err = api.get(QUERYSTATION, params, &soap); err != nil {
+	if err = api.get(ctx, QUERYSTATION, params, &soap); err != nil {
 		return res, err
 	}
 
-	return soap.Body.GetStartEndPointResponse.GetStartEndPointResult, nil
+	res = soap.Body.GetStartEndPointResponse.GetStartEndPointResult
+	if err = res.check(QUERYSTATION); err != nil {
+		return res, err
+	}
+	if len(res.StartPoints) == 0 {
+		return res, notFound(QUERYSTATION, ErrNoStationsFound)
+	}
+	return res, nil
 }
 
 //QueryPage returns matching start/end points
 func (api OpenApi) QueryPage(inpPointFr, inpPointTo string) (res GetStartEndPointResult, err error) {
+	return api.QueryPageContext(context.Background(), inpPointFr, inpPointTo)
+}
+
+//QueryPageContext is like QueryPage but carries ctx to the upstream request
+func (api OpenApi) QueryPageContext(ctx context.Context, inpPointFr, inpPointTo string) (res GetStartEndPointResult, err error) {
 
 	params := url.Values{}
 	params.Set("inpPointFr", inpPointFr)
 	params.Set("inpPointTo", inpPointTo)
 
 	soap := SOAPEnvelope{}
-	if err = api.get(QUERYPAGE, params, &soap); err != nil {
+	if err = api.get(ctx, QUERYPAGE, params, &soap); err != nil {
 		return res, err
 	}
 
-	return soap.Body.GetStartEndPointResponse.GetStartEndPointResult, nil
+	res = soap.Body.GetStartEndPointResponse.GetStartEndPointResult
+	if err = res.check(QUERYPAGE); err != nil {
+		return res, err
+	}
+	if len(res.StartPoints) == 0 || len(res.EndPoints) == 0 {
+		return res, notFound(QUERYPAGE, ErrNoStationsFound)
+	}
+	return res, nil
 }
 
 //ResultsPage returns list of journeys between two points
 func (api OpenApi) ResultsPage(cmdaction string, from, to Point, LastStart time.Time) (res GetJourneyResult, err error) {
+	return api.ResultsPageContext(context.Background(), cmdaction, from, to, LastStart)
+}
+
+//ResultsPageContext is like ResultsPage but carries ctx to the upstream request
+func (api OpenApi) ResultsPageContext(ctx context.Context, cmdaction string, from, to Point, LastStart time.Time) (res GetJourneyResult, err error) {
+	return api.ResultsPageDirectionContext(ctx, cmdaction, from, to, LastStart, DEPARTURE)
+}
+
+/*
+ResultsPageDirection is like ResultsPage, but selDirection tells if LastStart
+is the time to depart (DEPARTURE) or the time to arrive by (ARRIVAL).
+*/
+func (api OpenApi) ResultsPageDirection(cmdaction string, from, to Point, LastStart time.Time, selDirection int) (res GetJourneyResult, err error) {
+	return api.ResultsPageDirectionContext(context.Background(), cmdaction, from, to, LastStart, selDirection)
+}
+
+//ResultsPageDirectionContext is like ResultsPageDirection but carries ctx to the upstream request
+func (api OpenApi) ResultsPageDirectionContext(ctx context.Context, cmdaction string, from, to Point, LastStart time.Time, selDirection int) (res GetJourneyResult, err error) {
 
 	params := url.Values{}
 	params.Set("cmdaction", cmdaction)
 	params.Set("selPointFr", from.AsURIParameter())
 	params.Set("selPointTo", to.AsURIParameter())
-	params.Set("LastStart", LastStart.Format("2006-01-02 15:04"))
+	params.Set("LastStart", LastStart.In(Stockholm).Format("2006-01-02 15:04"))
 	params.Set("DetailedResult", "True")
+	if selDirection != DEPARTURE {
+		params.Set("selDirection", fmt.Sprintf("%d", selDirection))
+	}
 
 	soap := SOAPEnvelope{}
-	if err = api.get(RESULTSPAGE, params, &soap); err != nil {
+	if err = api.get(ctx, RESULTSPAGE, params, &soap); err != nil {
+		return res, err
+	}
+
+	res = soap.Body.GetJourneyResponse.GetJourneyResult
+	if err = res.check(RESULTSPAGE); err != nil {
 		return res, err
 	}
-	return soap.Body.GetJourneyResponse.GetJourneyResult, nil
+	if len(res.Journeys) == 0 {
+		return res, notFound(RESULTSPAGE, ErrNoJourneysFound)
+	}
+	return res, nil
 }
 
 //NearestStation returns stations nearby X,Y point, within radius R
 func (api OpenApi) NearestStation(x, y float64, R int) (res GetNearestStopAreaResult, err error) {
+	return api.NearestStationContext(context.Background(), x, y, R)
+}
+
+//NearestStationContext is like NearestStation but carries ctx to the upstream request
+func (api OpenApi) NearestStationContext(ctx context.Context, x, y float64, R int) (res GetNearestStopAreaResult, err error) {
 
 	params := url.Values{}
 	params.Set("x", fmt.Sprintf("%.0f", x))
@@ -358,25 +538,61 @@ func (api OpenApi) NearestStation(x, y float64, R int) (res GetNearestStopAreaRe
 	params.Set("R", fmt.Sprintf("%d", R))
 
 	soap := SOAPEnvelope{}
-	if err = api.get(NEARESTSTATION, params, &soap); err != nil {
+	if err = api.get(ctx, NEARESTSTATION, params, &soap); err != nil {
+		return res, err
+	}
+
+	res = soap.Body.GetNearestStopAreaResponse.GetNearestStopAreaResult
+	if err = res.check(NEARESTSTATION); err != nil {
 		return res, err
 	}
-	return soap.Body.GetNearestStopAreaResponse.GetNearestStopAreaResult, nil
+	if len(res.NearestStopAreas) == 0 {
+		return res, notFound(NEARESTSTATION, ErrNoStationsFound)
+	}
+	return res, nil
+}
+
+//NearestStationLatLon returns stations nearby a WGS84 position, within radius R
+func (api OpenApi) NearestStationLatLon(p LatLon, R int) (res GetNearestStopAreaResult, err error) {
+	return api.NearestStationLatLonContext(context.Background(), p, R)
+}
+
+//NearestStationLatLonContext is like NearestStationLatLon but carries ctx to the upstream request.
+//Positions outside SkaneBounds fail with ErrOutOfBounds, without a request.
+func (api OpenApi) NearestStationLatLonContext(ctx context.Context, p LatLon, R int) (res GetNearestStopAreaResult, err error) {
+	if err = p.Validate(); err != nil {
+		return res, &APIError{Endpoint: NEARESTSTATION, Err: err}
+	}
+	c := p.ToRT90()
+	return api.NearestStationContext(ctx, c.X, c.Y, R)
 }
 
 //StationResult returns timetable for a given station
 func (api OpenApi) StationResult(selPointFrKey int, t time.Time) (res GetDepartureArrivalResult, err error) {
+	return api.StationResultContext(context.Background(), selPointFrKey, t)
+}
+
+//StationResultContext is like StationResult but carries ctx to the upstream request
+func (api OpenApi) StationResultContext(ctx context.Context, selPointFrKey int, t time.Time) (res GetDepartureArrivalResult, err error) {
 
 	params := url.Values{}
 	params.Set("selPointFrKey", fmt.Sprintf("%d", selPointFrKey))
-	params.Set("inpDate", t.Format("060102"))
-	params.Set("inpTime", t.Format("1504"))
+	params.Set("inpDate", t.In(Stockholm).Format("060102"))
+	params.Set("inpTime", t.In(Stockholm).Format("1504"))
 
 	soap := SOAPEnvelope{}
-	if err = api.get(STATIONRESULT, params, &soap); err != nil {
+	if err = api.get(ctx, STATIONRESULT, params, &soap); err != nil {
 		return res, err
 	}
-	return soap.Body.GetDepartureArrivalResponse.GetDepartureArrivalResult, nil
+
+	res = soap.Body.GetDepartureArrivalResponse.GetDepartureArrivalResult
+	if err = res.check(STATIONRESULT); err != nil {
+		return res, err
+	}
+	if len(res.Lines) == 0 {
+		return res, notFound(STATIONRESULT, ErrNoDeparturesFound)
+	}
+	return res, nil
 }
 
 //GetStationResult returns timetable for a given station
@@ -384,19 +600,36 @@ func GetStationResult(stationID int, t time.Time) (res GetDepartureArrivalResult
 	return DefaultClient.StationResult(stationID, t)
 }
 
+//GetStationResultContext is like GetStationResult but carries ctx to the upstream request
+func GetStationResultContext(ctx context.Context, stationID int, t time.Time) (res GetDepartureArrivalResult, err error) {
+	return DefaultClient.StationResultContext(ctx, stationID, t)
+}
+
 //JourneyPath returns geo path for a given JourneyResultKey and sequence number
 func (api OpenApi) JourneyPath(cf string, sequenceNo int) (res GetJourneyPathResult, err error) {
+	return api.JourneyPathContext(context.Background(), cf, sequenceNo)
+}
+
+//JourneyPathContext is like JourneyPath but carries ctx to the upstream request
+func (api OpenApi) JourneyPathContext(ctx context.Context, cf string, sequenceNo int) (res GetJourneyPathResult, err error) {
 
 	params := url.Values{}
 	params.Set("cf", cf)
 	params.Set("id", fmt.Sprintf("%d", sequenceNo))
 
 	soap := SOAPEnvelope{}
-	if err = api.get(JOURNEYPATH, params, &soap); err != nil {
+	if err = api.get(ctx, JOURNEYPATH, params, &soap); err != nil {
 		return res, err
 	}
 
-	return soap.Body.GetJourneyPathResponse.GetJourneyPathResult, nil
+	res = soap.Body.GetJourneyPathResponse.GetJourneyPathResult
+	if err = res.check(JOURNEYPATH); err != nil {
+		return res, err
+	}
+	if len(strings.TrimSpace(string(res.ResultXML))) == 0 {
+		return res, notFound(JOURNEYPATH, ErrNoPathFound)
+	}
+	return res, nil
 }
 
 //Parts unmarshals the raw XML included in GetJourneyPathResult
@@ -410,7 +643,7 @@ func (res GetJourneyPathResult) Parts() (parts []Part, err error) {
 	r := ResultXML{}
 	err = xml.Unmarshal(data, &r)
 	if err != nil {
-		return nil, err
+		return nil, &APIError{Endpoint: JOURNEYPATH, Err: fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)}
 	}
 
 	return r.Parts, nil
@@ -1,56 +1,143 @@
 package openapi_test
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/peterstark72/skanetrafiken/openapi"
 )
 
-var api = openapi.NewOpenAPI()
+var api openapi.OpenApi
+
+//fixtureHandler serves canned SOAP envelopes from testdata, one file per endpoint
+func fixtureHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := os.ReadFile(filepath.Join("testdata", path.Base(r.URL.Path)+".xml"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write(data)
+}
+
+func TestMain(m *testing.M) {
+
+	srv := httptest.NewServer(http.HandlerFunc(fixtureHandler))
+
+	api = openapi.NewOpenAPI(openapi.WithBaseURL(srv.URL + "/"))
+	openapi.DefaultClient = &api
+
+	code := m.Run()
+	srv.Close()
+	os.Exit(code)
+}
 
 func TestQueryPage(t *testing.T) {
 
-	_, err := api.QueryPage("Lund", "Ystad")
+	res, err := api.QueryPage("Lund", "Ystad")
 	if err != nil {
 		t.Error(err)
+		return
 	}
 
+	if len(res.StartPoints) != 1 || res.StartPoints[0].Name != "Lund C" {
+		t.Errorf("Unexpected start points %v", res.StartPoints)
+	}
+	if len(res.EndPoints) != 1 || res.EndPoints[0].Id != 86300 {
+		t.Errorf("Unexpected end points %v", res.EndPoints)
+	}
 }
 
 func TestResultsPage(t *testing.T) {
 
-	_, err := api.ResultsPage("next",
+	res, err := api.ResultsPage("next",
 		openapi.Point{"Malmö C", 80000, "STOP_AREA", openapi.Coord{0, 0}},
 		openapi.Point{"Landskrona", 82000, "STOP_AREA", openapi.Coord{0, 0}},
 		time.Now())
 	if err != nil {
 		t.Error(err)
+		return
+	}
+
+	if res.JourneyResultKey != "1a2b3c4d" || len(res.Journeys) != 1 {
+		t.Errorf("Unexpected result %v", res)
+		return
+	}
+
+	links := res.Journeys[0].RouteLinks
+	if len(links) != 1 || links[0].Line.No != 1077 || links[0].RealTime.DepTimeDeviation != 3 {
+		t.Errorf("Unexpected route links %v", links)
 	}
 }
 
 func TestQueryStation(t *testing.T) {
 
-	_, err := api.QueryStation("Malmö")
+	res, err := api.QueryStation("Malmö")
 	if err != nil {
 		t.Error(err)
+		return
+	}
+
+	if len(res.StartPoints) != 3 {
+		t.Errorf("Expected 3 stations, got %d", len(res.StartPoints))
+		return
+	}
+
+	p := res.StartPoints[0]
+	if p.Name != "Malmö C" || p.Id != 80000 || p.Type != "STOP_AREA" || p.X != 6167946 || p.Y != 1323245 {
+		t.Errorf("Unexpected station %v", p)
 	}
 }
 
 func TestNearestStopAreas(t *testing.T) {
 
-	_, err := api.NearestStation(6167930, 1323215, 1000)
+	res, err := api.NearestStation(6167930, 1323215, 1000)
 	if err != nil {
 		t.Error(err)
+		return
 	}
 
+	if len(res.NearestStopAreas) != 2 || res.NearestStopAreas[1].Distance != 390 {
+		t.Errorf("Unexpected stop areas %v", res.NearestStopAreas)
+	}
+}
+
+func TestNearestStationLatLon(t *testing.T) {
+
+	res, err := api.NearestStationLatLon(openapi.LatLon{Lat: 55.609, Lon: 13.0}, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(res.NearestStopAreas) != 2 {
+		t.Errorf("Unexpected stop areas %v", res.NearestStopAreas)
+	}
+
+	//Swapped axes are caught before the request
+	_, err = api.NearestStationLatLon(openapi.LatLon{Lat: 13.0, Lon: 55.609}, 1000)
+	var apiErr *openapi.APIError
+	if !errors.Is(err, openapi.ErrOutOfBounds) || !errors.As(err, &apiErr) || apiErr.Endpoint != openapi.NEARESTSTATION {
+		t.Errorf("Expected ErrOutOfBounds, got %v", err)
+	}
 }
 
 func TestStationResult(t *testing.T) {
 
-	_, err := api.StationResult(80000, time.Now())
+	res, err := api.StationResult(80000, time.Now())
 	if err != nil {
 		t.Error(err)
+		return
+	}
+
+	if res.StopAreaData.Name != "Malmö C" || len(res.Lines) != 2 {
+		t.Errorf("Unexpected result %v", res)
 	}
 }
 
@@ -74,10 +161,52 @@ func TestJourneyPath(t *testing.T) {
 		return
 	}
 
-	_, err = path.Parts()
+	parts, err := path.Parts()
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	if len(parts) != 1 || len(parts[0].Coords) != 3 || parts[0].To.Name != "Landskrona" {
+		t.Errorf("Unexpected parts %v", parts)
+	}
+}
+
+func TestQueryStationContextCanceled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := api.QueryStationContext(ctx, "Malmö")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		fixtureHandler(w, r)
+	}))
+	defer srv.Close()
+
+	a := openapi.NewOpenAPI(
+		openapi.WithBaseURL(srv.URL+"/v2.2"),
+		openapi.WithHTTPClient(srv.Client()),
+		openapi.WithUserAgent("skanetrafiken-test/1.0"),
+		openapi.WithHeader("X-Api-Key", "secret"))
+
+	if _, err := a.QueryStation("Malmö"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if got.URL.Path != "/v2.2/querystation.asp" || got.URL.Query().Get("inpPointFr") != "Malmö" {
+		t.Errorf("Unexpected request %s", got.URL)
+	}
+	if got.UserAgent() != "skanetrafiken-test/1.0" || got.Header.Get("X-Api-Key") != "secret" {
+		t.Errorf("Unexpected headers %v", got.Header)
+	}
 }
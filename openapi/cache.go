@@ -0,0 +1,153 @@
+package openapi
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Cache stores raw upstream responses, keyed on the endpoint plus the
+encoded query parameters. Implementations must be safe for concurrent use.
+
+MemoryCache is an in-memory implementation, but anything that can
+store bytes with a TTL (e.g. memcache or Redis) will do.
+*/
+type Cache interface {
+	Get(key string) (data []byte, ok bool)
+	Set(key string, data []byte, ttl time.Duration)
+}
+
+//DefaultCacheTTL is how long responses from each endpoint are cached.
+//Endpoints not listed here are not cached.
+var DefaultCacheTTL = map[string]time.Duration{
+	QUERYSTATION:   24 * time.Hour,
+	QUERYPAGE:      24 * time.Hour,
+	NEARESTSTATION: 24 * time.Hour,
+	STATIONRESULT:  30 * time.Second,
+	RESULTSPAGE:    time.Minute,
+	JOURNEYPATH:    time.Hour,
+}
+
+//CacheStats counts cache lookups made by an OpenApi instance
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+type cacheCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+//WithCache makes the instance cache responses in c, using DefaultCacheTTL
+func WithCache(c Cache) Option {
+	return func(api *OpenApi) {
+		api.cache = c
+		api.cacheStats = new(cacheCounters)
+		if api.cacheTTL == nil {
+			api.cacheTTL = make(map[string]time.Duration)
+			for k, v := range DefaultCacheTTL {
+				api.cacheTTL[k] = v
+			}
+		}
+	}
+}
+
+//WithCacheTTL overrides the cache TTL for an endpoint, zero disables caching of it
+func WithCacheTTL(endpoint string, ttl time.Duration) Option {
+	return func(api *OpenApi) {
+		ttls := make(map[string]time.Duration)
+		for k, v := range DefaultCacheTTL {
+			ttls[k] = v
+		}
+		for k, v := range api.cacheTTL {
+			ttls[k] = v
+		}
+		ttls[endpoint] = ttl
+		api.cacheTTL = ttls
+	}
+}
+
+//CacheStats returns the number of cache hits and misses so far
+func (api OpenApi) CacheStats() CacheStats {
+	if api.cacheStats == nil {
+		return CacheStats{}
+	}
+	return CacheStats{api.cacheStats.hits.Load(), api.cacheStats.misses.Load()}
+}
+
+//MemoryCache is an in-memory LRU cache where entries also expire after their TTL
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type cacheEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+//NewMemoryCache creates a MemoryCache holding at most maxEntries responses
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+//Get returns the cached data for key, unless it is missing or expired
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.data, true
+}
+
+//Set stores data for key, evicting the least recently used entry if the cache is full
+func (c *MemoryCache) Set(key string, data []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.data, entry.expires = data, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key, data, expires})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.remove(c.ll.Back())
+	}
+}
+
+//Len returns the number of entries, including expired ones not yet evicted
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *MemoryCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,110 @@
+package openapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheLRU(t *testing.T) {
+
+	c := NewMemoryCache(2)
+	c.Set("a", []byte("A"), time.Minute)
+	c.Set("b", []byte("B"), time.Minute)
+	c.Get("a")
+	c.Set("c", []byte("C"), time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Least recently used entry should have been evicted")
+	}
+	if data, ok := c.Get("a"); !ok || string(data) != "A" {
+		t.Error("Recently used entry should remain")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+
+	now := time.Date(2014, 3, 10, 12, 0, 0, 0, time.UTC)
+	c := NewMemoryCache(10)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("A"), time.Minute)
+
+	now = now.Add(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("Entry should not have expired yet")
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Entry should have expired")
+	}
+}
+
+func TestCachedQueryStation(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/querystation.asp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	api := NewOpenAPI(WithBaseURL(srv.URL), WithCache(NewMemoryCache(100)), WithCacheTTL(STATIONRESULT, 0))
+
+	for i := 0; i < 3; i++ {
+		res, err := api.QueryStation("Malmö")
+		if err != nil || len(res.StartPoints) != 3 {
+			t.Errorf("Unexpected result %v, %v", res, err)
+		}
+	}
+	api.QueryStation("Lund")
+
+	if calls != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", calls)
+	}
+	if stats := api.CacheStats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if api.cacheTTL[QUERYSTATION] != DefaultCacheTTL[QUERYSTATION] || api.cacheTTL[STATIONRESULT] != 0 {
+		t.Errorf("Unexpected TTLs %v", api.cacheTTL)
+	}
+}
+
+//corruptCache returns data that is not XML for every key
+type corruptCache struct{}
+
+func (corruptCache) Get(key string) ([]byte, bool)                  { return []byte("<soap:Envelope"), true }
+func (corruptCache) Set(key string, data []byte, ttl time.Duration) {}
+
+func TestCorruptCacheEntry(t *testing.T) {
+
+	api := NewOpenAPI(WithBaseURL("http://127.0.0.1:0"), WithCache(corruptCache{}))
+
+	_, err := api.QueryStation("Malmö")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Endpoint != QUERYSTATION || !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("Expected APIError wrapping ErrUnexpectedResponse, got %v", err)
+	}
+}
+
+func TestWithCacheCopiesDefaultTTL(t *testing.T) {
+
+	api := NewOpenAPI(WithCache(NewMemoryCache(10)))
+	api.cacheTTL[QUERYSTATION] = 0
+
+	if DefaultCacheTTL[QUERYSTATION] == 0 {
+		t.Error("WithCache should not share DefaultCacheTTL")
+	}
+}
@@ -0,0 +1,80 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+LatLon is a WGS84 position in degrees. Coord is always RT90
+(rt90_2.5_gon_v), convert between them with Coord.ToWGS84 and
+LatLon.ToRT90 rather than passing bare float pairs around.
+*/
+type LatLon struct {
+	Lat float64
+	Lon float64
+}
+
+//String formats the position as "lat,lon"
+func (p LatLon) String() string {
+	return fmt.Sprintf("%.6f,%.6f", p.Lat, p.Lon)
+}
+
+//ToRT90 converts the position to RT90 coordinates
+func (p LatLon) ToRT90() Coord {
+	x, y := GeodeticToGrid(p.Lat, p.Lon)
+	return Coord{x, y}
+}
+
+//Pos converts LatLon to GeoJSON Position object, which is [lon, lat]
+func (p LatLon) Pos() [2]float64 {
+	return [2]float64{p.Lon, p.Lat}
+}
+
+//Distance calculates the distance (in meters) to o with Vincenty, falling back to Haversine
+func (p LatLon) Distance(o LatLon) float64 {
+	d, err := Vincenty(p.Lat, p.Lon, o.Lat, o.Lon)
+	if err != nil {
+		return Haversine(p.Lat, p.Lon, o.Lat, o.Lon)
+	}
+	return d
+}
+
+//Validate returns ErrOutOfBounds unless the position lies within SkaneBounds
+func (p LatLon) Validate() error {
+	if math.IsNaN(p.Lat) || math.IsNaN(p.Lon) || !SkaneBounds.Contains(p) {
+		return fmt.Errorf("%w: %s", ErrOutOfBounds, p)
+	}
+	return nil
+}
+
+//ToWGS84 converts the RT90 coordinates to a WGS84 position
+func (c Coord) ToWGS84() LatLon {
+	lat, lon := GridToGeodetic(c.X, c.Y)
+	return LatLon{lat, lon}
+}
+
+//Distance calculates the distance (in meters) to o on the GRS80 ellipsoid, see Vincenty
+func (c Coord) Distance(o Coord) float64 {
+	return c.ToWGS84().Distance(o.ToWGS84())
+}
+
+//Validate returns ErrOutOfBounds unless the coordinates lie within SkaneBounds
+func (c Coord) Validate() error {
+	return c.ToWGS84().Validate()
+}
+
+//Bounds is a WGS84 bounding box
+type Bounds struct {
+	Min LatLon
+	Max LatLon
+}
+
+//SkaneBounds covers Skåne, including Ven and Hallands Väderö. A box is
+//coarse, it also covers Copenhagen, which is served by the Öresund trains.
+var SkaneBounds = Bounds{LatLon{55.30, 12.40}, LatLon{56.60, 14.65}}
+
+//Contains reports whether p lies within the bounds
+func (b Bounds) Contains(p LatLon) bool {
+	return p.Lat >= b.Min.Lat && p.Lat <= b.Max.Lat && p.Lon >= b.Min.Lon && p.Lon <= b.Max.Lon
+}
@@ -0,0 +1,58 @@
+package openapi
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCoordToWGS84(t *testing.T) {
+
+	p := Coord{6158063, 1322703}.ToWGS84()
+	if math.Abs(p.Lat-55.51992) > 1e-5 || math.Abs(p.Lon-12.99795) > 1e-5 {
+		t.Errorf("Unexpected position %s", p)
+	}
+
+	c := p.ToRT90()
+	if math.Abs(c.X-6158063) > 0.001 || math.Abs(c.Y-1322703) > 0.001 {
+		t.Errorf("Unexpected coordinates %v", c)
+	}
+
+	// GeoJSON positions are [lon, lat]
+	if pos := (Coord{6158063, 1322703}).Pos(); pos != p.Pos() || pos[0] != p.Lon {
+		t.Errorf("Unexpected GeoJSON position %v", pos)
+	}
+}
+
+func TestLatLonValidate(t *testing.T) {
+
+	tests := []struct {
+		name string
+		p    LatLon
+		ok   bool
+	}{
+		{"malmö c", LatLon{55.609, 13.000}, true},
+		{"smygehuk", LatLon{55.337, 13.359}, true},
+		{"ven", LatLon{55.906, 12.690}, true},
+		{"båstad", LatLon{56.426, 12.855}, true},
+		{"kristianstad", LatLon{56.029, 14.156}, true},
+		{"swapped axes", LatLon{13.000, 55.609}, false},
+		{"københavn h", LatLon{55.672, 12.564}, true},
+		{"stockholm", LatLon{59.330, 18.059}, false},
+		{"zero", LatLon{}, false},
+		{"nan", LatLon{math.NaN(), 13.0}, false},
+	}
+	for _, tt := range tests {
+		err := tt.p.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: expected ok %t, got %v", tt.name, tt.ok, err)
+		}
+		if err != nil && !errors.Is(err, ErrOutOfBounds) {
+			t.Errorf("%s: expected ErrOutOfBounds, got %v", tt.name, err)
+		}
+	}
+
+	if err := (Coord{6167946, 1323245}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,105 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//Sentinel errors, use errors.Is to test for them
+var (
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrUnexpectedResponse  = errors.New("unexpected response")
+	ErrUpstreamStatus      = errors.New("upstream returned error status")
+	ErrNoStationsFound     = errors.New("no stations found")
+	ErrNoJourneysFound     = errors.New("no journeys found")
+	ErrNoDeparturesFound   = errors.New("no departures found")
+	ErrNoPathFound         = errors.New("no journey path found")
+	ErrOutOfBounds         = errors.New("position outside Skåne")
+)
+
+/*
+APIError is returned by all query methods when a request fails or
+returns no results. Use errors.As to get hold of it:
+
+	var apiErr *openapi.APIError
+	if errors.As(err, &apiErr) {
+		fmt.Println(apiErr.Endpoint, apiErr.Code, apiErr.Message)
+	}
+
+HTTPStatus is only set when upstream answered with a non-2xx status, and
+RetryAfter when that answer carried a Retry-After header.
+Code and Message are copied from the Status of the result.
+*/
+type APIError struct {
+	Endpoint   string
+	HTTPStatus int
+	Code       int
+	Message    string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	msg := "openapi: " + e.Endpoint
+	if e.HTTPStatus != 0 {
+		msg += fmt.Sprintf(": HTTP %d %s", e.HTTPStatus, http.StatusText(e.HTTPStatus))
+	}
+	if e.Code != 0 {
+		msg += fmt.Sprintf(": status %d", e.Code)
+		if e.Message != "" {
+			msg += " " + e.Message
+		}
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+//httpError maps a non-2xx HTTP response to an APIError
+func httpError(endpoint string, res *http.Response) error {
+	err := ErrUnexpectedResponse
+	if res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests {
+		err = ErrUpstreamUnavailable
+	}
+	return &APIError{
+		Endpoint:   endpoint,
+		HTTPStatus: res.StatusCode,
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+		Err:        err,
+	}
+}
+
+//parseRetryAfter parses the Retry-After header, which is either seconds or an HTTP date
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+//check returns an APIError if the Status reports an upstream failure
+func (s Status) check(endpoint string) error {
+	if s.Code == 0 {
+		return nil
+	}
+	return &APIError{Endpoint: endpoint, Code: s.Code, Message: s.Message, Err: ErrUpstreamStatus}
+}
+
+//notFound returns an APIError for an empty, but otherwise successful, result
+func notFound(endpoint string, err error) error {
+	return &APIError{Endpoint: endpoint, Err: err}
+}
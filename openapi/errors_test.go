@@ -0,0 +1,113 @@
+package openapi_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+const statusEnvelope = `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <GetDepartureArrivalResponse xmlns="http://www.etis.fskab.se/v1.0/ETISws">
+      <GetDepartureArrivalResult>
+        <Code>-1</Code>
+        <Message>Invalid stop area</Message>
+      </GetDepartureArrivalResult>
+    </GetDepartureArrivalResponse>
+  </soap:Body>
+</soap:Envelope>`
+
+const emptyEnvelope = `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <GetStartEndPointResponse xmlns="http://www.etis.fskab.se/v1.0/ETISws">
+      <GetStartEndPointResult>
+        <Code>0</Code>
+        <Message />
+        <StartPoints />
+      </GetStartEndPointResult>
+    </GetStartEndPointResponse>
+  </soap:Body>
+</soap:Envelope>`
+
+//newStaticAPI returns an OpenApi talking to a server that always answers with status and body
+func newStaticAPI(status int, body string) (openapi.OpenApi, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	return openapi.NewOpenAPI(openapi.WithBaseURL(srv.URL)), srv.Close
+}
+
+func TestHTTPError(t *testing.T) {
+
+	a, done := newStaticAPI(http.StatusInternalServerError, "<html>Server Error</html>")
+	defer done()
+
+	_, err := a.QueryStation("Malmö")
+	if !errors.Is(err, openapi.ErrUpstreamUnavailable) {
+		t.Errorf("Expected ErrUpstreamUnavailable, got %v", err)
+	}
+
+	var apiErr *openapi.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatus != 500 || apiErr.Endpoint != openapi.QUERYSTATION {
+		t.Errorf("Unexpected error %#v", err)
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+
+	a, done := newStaticAPI(http.StatusOK, "<html>Not SOAP")
+	defer done()
+
+	_, err := a.QueryStation("Malmö")
+	if !errors.Is(err, openapi.ErrUnexpectedResponse) {
+		t.Errorf("Expected ErrUnexpectedResponse, got %v", err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+
+	a, done := newStaticAPI(http.StatusOK, statusEnvelope)
+	defer done()
+
+	_, err := a.StationResult(1, time.Now())
+	if !errors.Is(err, openapi.ErrUpstreamStatus) {
+		t.Errorf("Expected ErrUpstreamStatus, got %v", err)
+	}
+
+	var apiErr *openapi.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -1 || apiErr.Message != "Invalid stop area" {
+		t.Errorf("Unexpected error %#v", err)
+	}
+}
+
+func TestNoStationsFound(t *testing.T) {
+
+	a, done := newStaticAPI(http.StatusOK, emptyEnvelope)
+	defer done()
+
+	_, err := a.QueryStation("Xyzzy")
+	if !errors.Is(err, openapi.ErrNoStationsFound) {
+		t.Errorf("Expected ErrNoStationsFound, got %v", err)
+	}
+	if errors.Is(err, openapi.ErrUpstreamUnavailable) {
+		t.Error("Empty result should not be reported as unavailable")
+	}
+}
+
+func TestTransportError(t *testing.T) {
+
+	a, done := newStaticAPI(http.StatusOK, "")
+	done()
+
+	_, err := a.QueryStation("Malmö")
+	if !errors.Is(err, openapi.ErrUpstreamUnavailable) {
+		t.Errorf("Expected ErrUpstreamUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package openapi
+
+import (
+	"context"
+	"sync"
+)
+
+/*
+flightGroup coalesces identical concurrent requests into one upstream call.
+
+The shared call runs with a context detached from the first caller, so
+one caller giving up does not fail the others. It is cancelled when all
+callers waiting for it have given up.
+*/
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	data    []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+//WithCoalescing toggles sharing of identical in-flight requests (same endpoint and parameters)
+func WithCoalescing(enabled bool) Option {
+	return func(api *OpenApi) {
+		if enabled {
+			api.flight = &flightGroup{calls: make(map[string]*flightCall)}
+		} else {
+			api.flight = nil
+		}
+	}
+}
+
+//do calls fn once for all concurrent callers with the same key and hands them the same data
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+
+		go func() {
+			c.data, c.err = fn(fctx)
+			g.forget(key, c)
+			cancel()
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.data, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup) forget(key string, c *flightCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,112 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//waitForWaiters blocks until n callers are waiting for the in-flight call with key
+func waitForWaiters(g *flightGroup, key string, n int) {
+	for {
+		g.mu.Lock()
+		c, ok := g.calls[key]
+		done := ok && c.waiters == n
+		g.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalescing(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/stationresults.asp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	api := NewOpenAPI(WithBaseURL(srv.URL), WithCoalescing(true))
+	at := time.Date(2014, 3, 10, 12, 30, 0, 0, Stockholm)
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]GetDepartureArrivalResult, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = api.StationResult(80000, at)
+		}(i)
+	}
+
+	waitForWaiters(api.flight, STATIONRESULT+"?inpDate=140310&inpTime=1230&selPointFrKey=80000", n)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls.Load())
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || len(results[i].Lines) != 2 {
+			t.Errorf("Unexpected result %d: %v, %v", i, results[i], errs[i])
+		}
+	}
+}
+
+func TestCoalescingCancel(t *testing.T) {
+
+	g := &flightGroup{calls: make(map[string]*flightCall)}
+
+	release := make(chan struct{})
+	fn := func(ctx context.Context) ([]byte, error) {
+		select {
+		case <-release:
+			return []byte("ok"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := g.do(ctx, "k", fn)
+		first <- err
+	}()
+	waitForWaiters(g, "k", 1)
+
+	second := make(chan []byte)
+	go func() {
+		data, _ := g.do(context.Background(), "k", fn)
+		second <- data
+	}()
+	waitForWaiters(g, "k", 2)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	close(release)
+	if data := <-second; string(data) != "ok" {
+		t.Errorf("Remaining caller should get the result, got %q", data)
+	}
+}
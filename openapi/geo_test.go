@@ -2,6 +2,7 @@ package openapi
 
 import (
 	"fmt"
+	"math"
 	"testing"
 )
 
@@ -26,22 +27,157 @@ func TestGeodeticToGrid(t *testing.T) {
 
 func TestGridDistance(t *testing.T) {
 
-	x, y := 6158063.0, 1322703.0
+	tests := []struct {
+		name           string
+		x1, y1, x2, y2 float64
+		want           int
+	}{
+		{"same point", 6158063, 1322703, 6158063, 1322703, 0},
+		{"northing only", 6158063, 1322703, 6158463, 1322703, 400},
+		{"easting only", 6158063, 1322703, 6158063, 1322403, 300},
+		{"both", 6158063, 1322703, 6158463, 1322403, 500},
+	}
+	for _, tt := range tests {
+		if d := GridDistance(tt.x1, tt.y1, tt.x2, tt.y2); d != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, d)
+		}
+	}
+}
 
-	d := GridDistance(x, y, x, y)
+func TestSphericalDistance(t *testing.T) {
 
-	if d != 0 {
+	lat, lon := 55.519919, 12.997947
+
+	if d := SphericalDistance(lat, lon, lat, lon); d != 0 {
 		t.Error("Distance between same points should be zero!")
 	}
+	if d := SphericalDistance(0, 0, 0, 1); d != 111195 {
+		t.Errorf("Expected 111195, got %d", d)
+	}
 }
 
-func TestSphericalDistance(t *testing.T) {
+func dms(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
 
-	lat, lon := 55.519919, 12.997947
+func TestHaversine(t *testing.T) {
+
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 55.6, 13.0, 55.6, 13.0, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111195.080},
+		{"one degree of longitude at equator", 0, 0, 0, 1, 111195.080},
+		{"one degree of longitude at 60N", 60, 13, 60, 14, 55597.011},
+		{"antipodal", 0, 0, 0, 180, 20015114.442},
+	}
+	for _, tt := range tests {
+		if d := Haversine(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(d-tt.want) > 0.001 {
+			t.Errorf("%s: expected %.3f, got %.3f", tt.name, tt.want, d)
+		}
+	}
+}
 
-	d := GridDistance(lat, lon, lat, lon)
+func TestVincenty(t *testing.T) {
+
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 55.6, 13.0, 55.6, 13.0, 0},
+		// Geoscience Australia reference, Flinders Peak to Buninyong
+		{"flinders peak", dms(-37, 57, 3.72030), dms(144, 25, 29.52440), dms(-37, 39, 10.15610), dms(143, 55, 35.38390), 54972.271},
+		{"one degree of longitude at equator", 0, 0, 0, 1, 111319.491},
+		{"meridian quadrant", 0, 0, 90, 0, 10001965.729},
+	}
+	for _, tt := range tests {
+		d, err := Vincenty(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if math.Abs(d-tt.want) > 0.001 {
+			t.Errorf("%s: expected %.3f, got %.3f", tt.name, tt.want, d)
+		}
+	}
 
-	if d != 0 {
-		t.Error("Distance between same points should be zero!")
+	if _, err := Vincenty(0, 0, 0.5, 179.7); err != ErrNoConvergence {
+		t.Errorf("Expected ErrNoConvergence for antipodal points, got %v", err)
+	}
+}
+
+func TestBearing(t *testing.T) {
+
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"north", 0, 0, 1, 0, 0},
+		{"east", 0, 0, 0, 1, 90},
+		{"south", 0, 0, -1, 0, 180},
+		{"west", 0, 0, 0, -1, 270},
+		{"malmö to lund", 55.609, 13.000, 55.705, 13.187, 47.621},
+	}
+	for _, tt := range tests {
+		if b := Bearing(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(b-tt.want) > 0.001 {
+			t.Errorf("%s: expected %.3f, got %.3f", tt.name, tt.want, b)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+
+	tests := []struct {
+		name                    string
+		lat, lon, bearing, dist float64
+		wantLat, wantLon        float64
+	}{
+		{"standing still", 55.6, 13.0, 45, 0, 55.6, 13.0},
+		{"one degree north", 0, 0, 0, 111195.080, 1, 0},
+		{"one degree east", 0, 0, 90, 111195.080, 0, 1},
+		{"across the antimeridian", 0, 179.5, 90, 111195.080, 0, -179.5},
+	}
+	for _, tt := range tests {
+		lat, lon := Destination(tt.lat, tt.lon, tt.bearing, tt.dist)
+		if math.Abs(lat-tt.wantLat) > 1e-6 || math.Abs(lon-tt.wantLon) > 1e-6 {
+			t.Errorf("%s: expected %.6f,%.6f, got %.6f,%.6f", tt.name, tt.wantLat, tt.wantLon, lat, lon)
+		}
+	}
+
+	//Going back from the destination lands on the origin
+	lat, lon := Destination(55.609, 13.0, Bearing(55.609, 13.0, 55.705, 13.187), Haversine(55.609, 13.0, 55.705, 13.187))
+	if math.Abs(lat-55.705) > 1e-6 || math.Abs(lon-13.187) > 1e-6 {
+		t.Errorf("Expected 55.705,13.187, got %.6f,%.6f", lat, lon)
+	}
+}
+
+func TestPolylineLength(t *testing.T) {
+
+	grid := func(lat, lon float64) Coord {
+		x, y := GeodeticToGrid(lat, lon)
+		return Coord{x, y}
+	}
+
+	tests := []struct {
+		name   string
+		coords []Coord
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"single point", []Coord{grid(55.6, 13.0)}, 0},
+		{"two points", []Coord{grid(55.6, 13.0), grid(55.6, 13.1)}, 6303.6},
+		{"back and forth", []Coord{grid(55.6, 13.0), grid(55.6, 13.1), grid(55.6, 13.0)}, 12607.1},
+	}
+	for _, tt := range tests {
+		if l := PolylineLength(tt.coords); math.Abs(l-tt.want) > 0.1 {
+			t.Errorf("%s: expected %.1f, got %.1f", tt.name, tt.want, l)
+		}
 	}
 }
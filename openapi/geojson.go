@@ -1,11 +1,19 @@
 /*
 
-Methods and structs for creating GeoJSON objects
+Methods and structs for creating RFC 7946 GeoJSON objects
 for the Open API methods that return any of the following types:
 
 - GetStartEndPointResult
 - GetNearestStopAreaResult
 - GetJourneyPathResult
+- GetJourneyResult
+- GetDepartureArrivalResult
+
+They all implement GeoJsonObject, and are written with a GeoJsonEncoder:
+
+	enc := openapi.NewGeoJsonEncoder(w)
+	enc.SetPrecision(5)
+	err := enc.Encode(res)
 
 */
 
@@ -13,7 +21,10 @@ package openapi
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"math"
+	"time"
 )
 
 const (
@@ -21,6 +32,9 @@ const (
 	FeatureType           = "Feature"
 )
 
+//DefaultPrecision is the number of decimals of encoded positions, about 10 cm
+const DefaultPrecision = 6
+
 type Properties struct {
 	Name     string `json:"name"`
 	Type     string `json:"type"`
@@ -28,9 +42,10 @@ type Properties struct {
 	Distance int    `json:"distance"`
 }
 
-type GeometryMultiPoint struct {
-	Type        string       `json:"type"`
-	Coordinates [][2]float64 `json:"coordinates"`
+//Geometry is implemented by the GeoJSON geometry types below
+type Geometry interface {
+	positions() [][2]float64
+	round(precision int) Geometry
 }
 
 type GeometryPoint struct {
@@ -38,114 +53,454 @@ type GeometryPoint struct {
 	Coordinates [2]float64 `json:"coordinates"`
 }
 
+type GeometryMultiPoint struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+type GeometryLineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+type GeometryMultiLineString struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+func NewPoint(pos [2]float64) GeometryPoint {
+	return GeometryPoint{"Point", pos}
+}
+
+func NewMultiPoint(pos [][2]float64) GeometryMultiPoint {
+	return GeometryMultiPoint{"MultiPoint", pos}
+}
+
+func NewLineString(pos [][2]float64) GeometryLineString {
+	return GeometryLineString{"LineString", pos}
+}
+
+func NewMultiLineString(lines [][][2]float64) GeometryMultiLineString {
+	return GeometryMultiLineString{"MultiLineString", lines}
+}
+
+func (g GeometryPoint) positions() [][2]float64      { return [][2]float64{g.Coordinates} }
+func (g GeometryMultiPoint) positions() [][2]float64 { return g.Coordinates }
+func (g GeometryLineString) positions() [][2]float64 { return g.Coordinates }
+
+func (g GeometryMultiLineString) positions() (pos [][2]float64) {
+	for _, l := range g.Coordinates {
+		pos = append(pos, l...)
+	}
+	return pos
+}
+
+func (g GeometryPoint) round(precision int) Geometry {
+	g.Coordinates = roundPos(g.Coordinates, precision)
+	return g
+}
+
+func (g GeometryMultiPoint) round(precision int) Geometry {
+	g.Coordinates = roundLine(g.Coordinates, precision)
+	return g
+}
+
+func (g GeometryLineString) round(precision int) Geometry {
+	g.Coordinates = roundLine(g.Coordinates, precision)
+	return g
+}
+
+func (g GeometryMultiLineString) round(precision int) Geometry {
+	lines := make([][][2]float64, len(g.Coordinates))
+	for n, l := range g.Coordinates {
+		lines[n] = roundLine(l, precision)
+	}
+	g.Coordinates = lines
+	return g
+}
+
+func roundPos(pos [2]float64, precision int) [2]float64 {
+	f := math.Pow10(precision)
+	return [2]float64{math.Round(pos[0]*f) / f, math.Round(pos[1]*f) / f}
+}
+
+func roundLine(line [][2]float64, precision int) [][2]float64 {
+	rounded := make([][2]float64, len(line))
+	for n, pos := range line {
+		rounded[n] = roundPos(pos, precision)
+	}
+	return rounded
+}
+
+//bbox returns the [west, south, east, north] bounding box of positions, or nil if there are none
+func bbox(pos [][2]float64) []float64 {
+	if len(pos) == 0 {
+		return nil
+	}
+	b := []float64{pos[0][0], pos[0][1], pos[0][0], pos[0][1]}
+	for _, p := range pos[1:] {
+		b[0], b[1] = math.Min(b[0], p[0]), math.Min(b[1], p[1])
+		b[2], b[3] = math.Max(b[2], p[0]), math.Max(b[3], p[1])
+	}
+	return b
+}
+
+//Feature ids are strings made of the role and the id of the object, e.g. "start:80000", "part:0:line"
 type Feature struct {
 	Type       string      `json:"type"`
-	Geometry   interface{} `json:"geometry"`
+	Id         string      `json:"id"`
+	BBox       []float64   `json:"bbox,omitempty"`
+	Geometry   Geometry    `json:"geometry"`
 	Properties interface{} `json:"properties"`
-	Id         int         `json:"id"`
+}
+
+func NewFeature(id string, geometry Geometry, properties interface{}) Feature {
+	return Feature{Type: FeatureType, Id: id, Geometry: geometry, Properties: properties}
 }
 
 type FeatureCollection struct {
 	Type     string    `json:"type"`
+	BBox     []float64 `json:"bbox,omitempty"`
 	Features []Feature `json:"features"`
 }
 
-type GeoJsonEncoder struct {
-	writer io.Writer
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: FeatureCollectionType, Features: features}
 }
 
+//GeoJsonObject is implemented by the results that can be written as GeoJSON
 type GeoJsonObject interface {
-	GeoJsonFeatures() (features []Feature)
+	GeoJsonFeatures() ([]Feature, error)
 }
 
-func NewFeatureCollection(features []Feature) FeatureCollection {
-	return FeatureCollection{FeatureCollectionType, features}
+/*
+GeoJsonEncoder writes GeoJsonObjects as RFC 7946 feature collections.
+Positions are rounded to DefaultPrecision decimals, and the collection and
+every feature that is not a Point get a bounding box, unless changed with
+SetPrecision and SetBBox.
+*/
+type GeoJsonEncoder struct {
+	writer    io.Writer
+	precision int
+	bbox      bool
+}
+
+func NewGeoJsonEncoder(w io.Writer) *GeoJsonEncoder {
+	return &GeoJsonEncoder{writer: w, precision: DefaultPrecision, bbox: true}
+}
+
+//SetPrecision sets the number of decimals of positions, a negative precision writes them unrounded
+func (enc *GeoJsonEncoder) SetPrecision(precision int) {
+	enc.precision = precision
+}
+
+//SetBBox turns bounding boxes on or off
+func (enc *GeoJsonEncoder) SetBBox(on bool) {
+	enc.bbox = on
+}
+
+//Encode writes obj as a FeatureCollection, or returns the error of its GeoJsonFeatures
+func (enc *GeoJsonEncoder) Encode(obj GeoJsonObject) error {
+
+	features, err := obj.GeoJsonFeatures()
+	if err != nil {
+		return err
+	}
+
+	fc := NewFeatureCollection(make([]Feature, len(features)))
+	var all [][2]float64
+	for n, f := range features {
+		if f.Geometry != nil {
+			if enc.precision >= 0 {
+				f.Geometry = f.Geometry.round(enc.precision)
+			}
+			pos := f.Geometry.positions()
+			if _, point := f.Geometry.(GeometryPoint); enc.bbox && !point {
+				f.BBox = bbox(pos)
+			}
+			all = append(all, pos...)
+		}
+		fc.Features[n] = f
+	}
+	if enc.bbox {
+		fc.BBox = bbox(all)
+	}
+
+	return json.NewEncoder(enc.writer).Encode(fc)
 }
 
 //Pos converts Coord to GeoJSON Position object
 func (c Coord) Pos() [2]float64 {
-	lat, lon := GridToGeodetic(c.X, c.Y)
-	return [2]float64{lon, lat}
+	return c.ToWGS84().Pos()
 }
 
-//WriteJSON writes GetStartEndPointResult as a GeoJSON object
-func (res GetStartEndPointResult) WriteJSON(w io.Writer) error {
+//GeoJsonFeatures returns start and end points as Point features
+func (res GetStartEndPointResult) GeoJsonFeatures() ([]Feature, error) {
 
 	var features []Feature
 
 	for _, p := range res.StartPoints {
-		feature := Feature{"Feature", GeometryPoint{"Point", p.Pos()},
-			Properties{p.Name, p.Type, "START", 0}, p.Id}
-
-		features = append(features, feature)
+		features = append(features, NewFeature(fmt.Sprintf("start:%d", p.Id), NewPoint(p.Pos()),
+			Properties{p.Name, p.Type, "START", 0}))
 	}
 
 	for _, p := range res.EndPoints {
-		feature := Feature{"Feature", GeometryPoint{"Point", p.Pos()},
-			Properties{p.Name, p.Type, "END", 0}, p.Id}
-
-		features = append(features, feature)
+		features = append(features, NewFeature(fmt.Sprintf("end:%d", p.Id), NewPoint(p.Pos()),
+			Properties{p.Name, p.Type, "END", 0}))
 	}
 
-	return json.NewEncoder(w).Encode(FeatureCollection{FeatureCollectionType, features})
+	return features, nil
+}
+
+//WriteJSON writes GetStartEndPointResult as a GeoJSON object
+func (res GetStartEndPointResult) WriteJSON(w io.Writer) error {
+	return NewGeoJsonEncoder(w).Encode(res)
+}
+
+//GeoJsonFeatures returns the stop areas as Point features
+func (res GetNearestStopAreaResult) GeoJsonFeatures() ([]Feature, error) {
+
+	var features []Feature
+
+	for _, p := range res.NearestStopAreas {
+		features = append(features, NewFeature(fmt.Sprintf("nearby:%d", p.Id), NewPoint(p.Pos()),
+			Properties{p.Name, "STOP_AREA", "NEARBY", p.Distance}))
+	}
 
+	return features, nil
 }
 
 //WriteJSON writes GetNearestStopAreaResult as a GeoJSON object
 func (res GetNearestStopAreaResult) WriteJSON(w io.Writer) error {
+	return NewGeoJsonEncoder(w).Encode(res)
+}
+
+//Geometry returns the path of the part as a LineString. Missing coordinates
+//split the path, which then is a MultiLineString. Without any usable path,
+//it is a straight line between From and To, or nil if either is missing.
+func (p Part) Geometry() Geometry {
+
+	var lines [][][2]float64
+	var line [][2]float64
+	flush := func() {
+		if len(line) > 1 {
+			lines = append(lines, line)
+		}
+		line = nil
+	}
+	for _, c := range p.Coords {
+		if c.X > 0 && c.Y > 0 {
+			line = append(line, c.Pos())
+		} else {
+			flush()
+		}
+	}
+	flush()
+
+	switch len(lines) {
+	case 0:
+		if p.From.X <= 0 || p.From.Y <= 0 || p.To.X <= 0 || p.To.Y <= 0 {
+			return nil
+		}
+		return NewLineString([][2]float64{p.From.Pos(), p.To.Pos()})
+	case 1:
+		return NewLineString(lines[0])
+	}
+	return NewMultiLineString(lines)
+}
+
+//GeoJsonFeatures returns each part as its from Point, its line and its to Point
+func (res GetJourneyPathResult) GeoJsonFeatures() ([]Feature, error) {
+
+	parts, err := res.Parts()
+	if err != nil {
+		return nil, err
+	}
 
 	var features []Feature
 
-	for _, p := range res.NearestStopAreas {
+	for n, p := range parts {
 
-		feature := Feature{"Feature", GeometryPoint{"Point", p.Pos()},
-			Properties{p.Name, "STOP_AREA", "NEARBY", p.Distance}, p.Id}
+		p.Line.Distance = GridDistance(p.From.X, p.From.Y, p.To.X, p.To.Y)
 
-		features = append(features, feature)
+		features = append(features,
+			NewFeature(fmt.Sprintf("part:%d:from", n), pointGeometry(p.From.Coord), p.From),
+			NewFeature(fmt.Sprintf("part:%d:line", n), p.Geometry(), p.Line),
+			NewFeature(fmt.Sprintf("part:%d:to", n), pointGeometry(p.To.Coord), p.To))
 	}
 
-	return json.NewEncoder(w).Encode(FeatureCollection{FeatureCollectionType, features})
+	return features, nil
 }
 
 //WriteJSON writes GetJourneyPathResult as a GeoJSON object
 func (res GetJourneyPathResult) WriteJSON(w io.Writer) error {
+	return NewGeoJsonEncoder(w).Encode(res)
+}
 
-	var features []Feature
+//GeoJsonWriter writes a GeoJsonObject as GeoJSON with WriteJSON, for the
+//results whose own WriteJSON writes plain JSON, e.g. GetJourneyResult
+type GeoJsonWriter struct {
+	GeoJsonObject
+}
 
-	parts, _ := res.Parts()
+func (g GeoJsonWriter) WriteJSON(w io.Writer) error {
+	return NewGeoJsonEncoder(w).Encode(g.GeoJsonObject)
+}
 
-	for n, p := range parts {
+//pointGeometry returns a Point, or nil for a missing coordinate, which is written as a null geometry
+func pointGeometry(c Coord) Geometry {
+	if c.X <= 0 || c.Y <= 0 {
+		return nil
+	}
+	return NewPoint(c.Pos())
+}
 
-		var coords [][2]float64
-		for c := range p.Coords {
-			if p.Coords[c].X > 0 && p.Coords[c].Y > 0 {
-				coords = append(coords, p.Coords[c].Pos())
+//routeLinkProperties are the properties of a route link feature
+type routeLinkProperties struct {
+	Journey           int               `json:"journey"`
+	JourneyKey        string            `json:"journeyKey"`
+	Line              string            `json:"line"`
+	LineNo            int               `json:"lineNo"`
+	Mode              string            `json:"mode"`
+	Towards           string            `json:"towards"`
+	From              string            `json:"from"`
+	FromId            int               `json:"fromId"`
+	To                string            `json:"to"`
+	ToId              int               `json:"toId"`
+	DepDateTime       SkanetrafikenTime `json:"depDateTime"`
+	ArrDateTime       SkanetrafikenTime `json:"arrDateTime"`
+	ExpectedDeparture SkanetrafikenTime `json:"expectedDeparture"`
+	ExpectedArrival   SkanetrafikenTime `json:"expectedArrival"`
+	DepDelayMinutes   int               `json:"depDelayMinutes"`
+	ArrDelayMinutes   int               `json:"arrDelayMinutes"`
+	Canceled          bool              `json:"canceled"`
+}
+
+//GeoJsonFeatures returns every route link of every journey as a straight LineString from From to To
+func (res GetJourneyResult) GeoJsonFeatures() ([]Feature, error) {
+
+	var features []Feature
+
+	for _, j := range res.Journeys {
+		for n, r := range j.RouteLinks {
+
+			var geometry Geometry
+			if r.From.X > 0 && r.From.Y > 0 && r.To.X > 0 && r.To.Y > 0 {
+				geometry = NewLineString([][2]float64{r.From.Pos(), r.To.Pos()})
 			}
-		}
 
-		start := Feature{"Feature", GeometryPoint{"Point", p.From.Pos()}, p.From, p.From.Id}
-		end := Feature{"Feature", GeometryPoint{"Point", p.To.Pos()}, p.To, p.To.Id}
+			features = append(features, NewFeature(fmt.Sprintf("journey:%d:link:%d", j.SequenceNo, n), geometry,
+				routeLinkProperties{
+					Journey:           j.SequenceNo,
+					JourneyKey:        j.JourneyKey,
+					Line:              r.Line.Name,
+					LineNo:            r.Line.No,
+					Mode:              r.Line.TransportModeName,
+					Towards:           r.Line.Towards,
+					From:              r.From.Name,
+					FromId:            r.From.Id,
+					To:                r.To.Name,
+					ToId:              r.To.Id,
+					DepDateTime:       r.DepDateTime,
+					ArrDateTime:       r.ArrDateTime,
+					ExpectedDeparture: SkanetrafikenTime{r.ExpectedDeparture()},
+					ExpectedArrival:   SkanetrafikenTime{r.ExpectedArrival()},
+					DepDelayMinutes:   r.RealTime.DepTimeDeviation,
+					ArrDelayMinutes:   r.RealTime.ArrTimeDeviation,
+					Canceled:          r.IsCanceled(),
+				}))
+		}
+	}
 
-		p.Line.Distance = GridDistance(p.From.X, p.From.Y, p.To.X, p.To.Y)
+	return features, nil
+}
 
-		line := Feature{"Feature",
-			GeometryMultiPoint{"LineString", coords}, p.Line, n}
+//departureProperties are the properties of the stop feature of a departure board
+type departureProperties struct {
+	Name       string     `json:"name"`
+	Departures []lineJSON `json:"departures"`
+}
 
-		features = append(features, start)
-		features = append(features, line)
-		features = append(features, end)
+//GeoJsonFeatures returns the stop as a single Point feature, with its departures as properties
+func (res GetDepartureArrivalResult) GeoJsonFeatures() ([]Feature, error) {
 
+	lines := make([]lineJSON, len(res.Lines))
+	for n, l := range res.Lines {
+		lines[n] = newLineJSON(l)
 	}
 
-	return json.NewEncoder(w).Encode(FeatureCollection{FeatureCollectionType, features})
+	return []Feature{NewFeature("stop", pointGeometry(res.StopAreaData.Coord),
+		departureProperties{res.StopAreaData.Name, lines})}, nil
 }
 
-//WriteJSON writes GetDepartureArrivalResult as a JSON object
+//lineJSON is a Line with its real-time adjusted departure
+type lineJSON struct {
+	Line
+	ExpectedDeparture SkanetrafikenTime
+	DelayMinutes      int
+}
+
+func newLineJSON(l Line) lineJSON {
+	return lineJSON{l, SkanetrafikenTime{l.ExpectedDeparture()}, l.DelayMinutes()}
+}
+
+//routeLinkJSON is a RouteLink with its real-time adjusted times
+type routeLinkJSON struct {
+	RouteLink
+	ExpectedDeparture SkanetrafikenTime
+	ExpectedArrival   SkanetrafikenTime
+	DepDelayMinutes   int
+	ArrDelayMinutes   int
+}
+
+//journeyJSON is a Journey with its real-time adjusted times
+type journeyJSON struct {
+	Journey
+	RouteLinks        []routeLinkJSON
+	ExpectedDeparture SkanetrafikenTime
+	ExpectedArrival   SkanetrafikenTime
+	ExpectedDuration  int
+	DelayMinutes      int
+}
+
+func newJourneyJSON(j Journey) journeyJSON {
+	links := make([]routeLinkJSON, len(j.RouteLinks))
+	for n, r := range j.RouteLinks {
+		links[n] = routeLinkJSON{r,
+			SkanetrafikenTime{r.ExpectedDeparture()},
+			SkanetrafikenTime{r.ExpectedArrival()},
+			r.RealTime.DepTimeDeviation,
+			r.RealTime.ArrTimeDeviation}
+	}
+	return journeyJSON{j, links,
+		SkanetrafikenTime{j.ExpectedDeparture()},
+		SkanetrafikenTime{j.ExpectedArrival()},
+		int(j.ExpectedDuration() / time.Minute),
+		j.ArrDelayMinutes()}
+}
+
+//WriteJSON writes GetDepartureArrivalResult as a JSON object,
+//where each line also has its expected departure and delay
 func (res GetDepartureArrivalResult) WriteJSON(w io.Writer) error {
-	return json.NewEncoder(w).Encode(res.Lines)
+	lines := make([]lineJSON, len(res.Lines))
+	for n, l := range res.Lines {
+		lines[n] = newLineJSON(l)
+	}
+	return json.NewEncoder(w).Encode(lines)
 }
 
-//WriteJSON writes GetJourneyResult as a JSON object
+//WriteJSON writes GetJourneyResult as a JSON object,
+//where journeys and route links also have their expected times and delays
 func (res GetJourneyResult) WriteJSON(w io.Writer) error {
-	return json.NewEncoder(w).Encode(res)
+	journeys := make([]journeyJSON, len(res.Journeys))
+	for n, j := range res.Journeys {
+		journeys[n] = newJourneyJSON(j)
+	}
+	return json.NewEncoder(w).Encode(struct {
+		GetJourneyResult
+		Journeys []journeyJSON
+	}{res, journeys})
 }
@@ -0,0 +1,206 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const pathXML = `<Part><Line><Name>Öresundståg</Name><No>1077</No></Line>` +
+	`<From><Id>80000</Id><Name>Malmö C</Name><X>6167946</X><Y>1323245</Y></From>` +
+	`<To><Id>82000</Id><Name>Landskrona</Name><X>6197478</X><Y>1311283</Y></To>` +
+	`<Coords><Coord><X>6167946</X><Y>1323245</Y></Coord><Coord><X>6176609</X><Y>1335904</Y></Coord>` +
+	`<Coord><X>6197478</X><Y>1311283</Y></Coord></Coords></Part>` +
+	`<Part><Line><Name>Buss 1</Name><No>1</No></Line>` +
+	`<From><Id>82000</Id><Name>Landskrona</Name><X>6197478</X><Y>1311283</Y></From>` +
+	`<To><Id>82010</Id><Name>Landskrona Centrum</Name><X>6197900</X><Y>1311900</Y></To>` +
+	`<Coords><Coord><X>6197478</X><Y>1311283</Y></Coord><Coord><X>6197600</X><Y>1311500</Y></Coord>` +
+	`<Coord><X>0</X><Y>0</Y></Coord>` +
+	`<Coord><X>6197700</X><Y>1311700</Y></Coord><Coord><X>6197900</X><Y>1311900</Y></Coord></Coords></Part>`
+
+//geoJSON is the decoded output of the encoder
+type geoJSON struct {
+	Type     string
+	BBox     []float64
+	Features []struct {
+		Type     string
+		Id       string
+		BBox     []float64
+		Geometry struct {
+			Type        string
+			Coordinates json.RawMessage
+		}
+	}
+}
+
+func encodeGeoJSON(t *testing.T, enc func(*GeoJsonEncoder), obj GeoJsonObject) (buf bytes.Buffer, res geoJSON) {
+	e := NewGeoJsonEncoder(&buf)
+	if enc != nil {
+		enc(e)
+	}
+	if err := e.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return buf, res
+}
+
+func TestGeoJsonPath(t *testing.T) {
+
+	_, res := encodeGeoJSON(t, nil, GetJourneyPathResult{ResultXML: []byte(pathXML)})
+
+	want := []struct{ id, geometry string }{
+		{"part:0:from", "Point"},
+		{"part:0:line", "LineString"},
+		{"part:0:to", "Point"},
+		{"part:1:from", "Point"},
+		{"part:1:line", "MultiLineString"},
+		{"part:1:to", "Point"},
+	}
+	if res.Type != "FeatureCollection" || len(res.Features) != len(want) {
+		t.Fatalf("Unexpected feature collection %v", res)
+	}
+	for n, w := range want {
+		f := res.Features[n]
+		if f.Type != "Feature" || f.Id != w.id || f.Geometry.Type != w.geometry {
+			t.Errorf("Expected %s %s, got %s %s", w.id, w.geometry, f.Id, f.Geometry.Type)
+		}
+		if (f.BBox == nil) != (w.geometry == "Point") {
+			t.Errorf("%s: unexpected bbox %v", f.Id, f.BBox)
+		}
+	}
+
+	// The collection bbox covers all parts, [west, south, east, north]
+	malmo, centrum := Coord{6167946, 1323245}.ToWGS84(), Coord{6197900, 1311900}.ToWGS84()
+	if len(res.BBox) != 4 || res.BBox[1] != roundPos(malmo.Pos(), 6)[1] || res.BBox[3] != roundPos(centrum.Pos(), 6)[1] {
+		t.Errorf("Unexpected bbox %v", res.BBox)
+	}
+}
+
+func TestGeoJsonPathError(t *testing.T) {
+
+	err := NewGeoJsonEncoder(&bytes.Buffer{}).Encode(GetJourneyPathResult{ResultXML: []byte("<Part><Line>")})
+	if !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("Expected ErrUnexpectedResponse, got %v", err)
+	}
+}
+
+func TestGeoJsonPrecision(t *testing.T) {
+
+	res := GetNearestStopAreaResult{NearestStopAreas: []NearestStopArea{
+		{Point{"Malmö C", 80000, "STOP_AREA", Coord{6167946, 1323245}}, 0},
+	}}
+
+	tests := []struct {
+		precision int
+		want      string
+	}{
+		{0, `[13,56]`},
+		{3, `[13,55.609]`},
+		{4, `[13.0002,55.6088]`},
+		{6, `[13.0002,55.608777]`},
+	}
+	for _, tt := range tests {
+		_, fc := encodeGeoJSON(t, func(e *GeoJsonEncoder) { e.SetPrecision(tt.precision) }, res)
+		if got := string(fc.Features[0].Geometry.Coordinates); got != tt.want {
+			t.Errorf("Precision %d: expected %s, got %s", tt.precision, tt.want, got)
+		}
+	}
+
+	buf, fc := encodeGeoJSON(t, func(e *GeoJsonEncoder) { e.SetBBox(false) }, res)
+	if fc.BBox != nil || bytes.Contains(buf.Bytes(), []byte("bbox")) {
+		t.Errorf("Unexpected bbox in %s", buf.String())
+	}
+	if fc.Features[0].Id != "nearby:80000" {
+		t.Errorf("Unexpected id %s", fc.Features[0].Id)
+	}
+}
+
+func TestGeoJsonEmpty(t *testing.T) {
+
+	buf, _ := encodeGeoJSON(t, nil, GetStartEndPointResult{})
+	if got := buf.String(); got != `{"type":"FeatureCollection","features":[]}`+"\n" {
+		t.Errorf("Unexpected empty collection %s", got)
+	}
+}
+
+func TestGeoJsonJourneys(t *testing.T) {
+
+	j := testJourney()
+	j.SequenceNo = 2
+	j.RouteLinks[0].From = Point{"Malmö C", 80000, "STOP_AREA", Coord{6167946, 1323245}}
+	j.RouteLinks[0].To = Point{"Lund C", 81216, "STOP_AREA", Coord{6175867, 1335132}}
+	j.RouteLinks[0].Line = Line{Name: "Pågatåg", No: 12, TransportModeName: "Train"}
+
+	var fc struct {
+		Features []struct {
+			Id         string
+			Geometry   *struct{ Type string }
+			Properties map[string]interface{}
+		}
+	}
+	buf, _ := encodeGeoJSON(t, nil, GetJourneyResult{Journeys: []Journey{j}})
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fc.Features) != 2 {
+		t.Fatalf("Expected a feature per route link, got %d", len(fc.Features))
+	}
+
+	f := fc.Features[0]
+	if f.Id != "journey:2:link:0" || f.Geometry == nil || f.Geometry.Type != "LineString" {
+		t.Errorf("Unexpected feature %s %v", f.Id, f.Geometry)
+	}
+	props := f.Properties
+	if props["line"] != "Pågatåg" || props["mode"] != "Train" || props["from"] != "Malmö C" || props["toId"] != 81216.0 ||
+		props["expectedDeparture"] != "2014-03-10T12:36:00+01:00" || props["depDelayMinutes"] != 2.0 {
+		t.Errorf("Unexpected properties %v", props)
+	}
+
+	//The second route link has no coordinates
+	if f = fc.Features[1]; f.Id != "journey:2:link:1" || f.Geometry != nil {
+		t.Errorf("Expected null geometry, got %s %v", f.Id, f.Geometry)
+	}
+}
+
+func TestGeoJsonDepartures(t *testing.T) {
+
+	res := GetDepartureArrivalResult{
+		StopAreaData: StopAreaData{"Malmö C", Coord{6167946, 1323245}},
+		Lines: []Line{
+			{Name: "Öresundståg", JourneyDateTime: mustParseTime("2014-03-10T12:34:00"), RealTime: RealTimeInfo{DepTimeDeviation: 3}},
+		},
+	}
+
+	var fc struct {
+		Features []struct {
+			Id         string
+			Geometry   struct{ Type string }
+			Properties struct {
+				Name       string
+				Departures []struct {
+					Name              string
+					ExpectedDeparture string
+					DelayMinutes      int
+				}
+			}
+		}
+	}
+	buf, _ := encodeGeoJSON(t, nil, res)
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fc.Features) != 1 || fc.Features[0].Id != "stop" || fc.Features[0].Geometry.Type != "Point" {
+		t.Fatalf("Unexpected features %v", fc.Features)
+	}
+	props := fc.Features[0].Properties
+	if props.Name != "Malmö C" || len(props.Departures) != 1 ||
+		props.Departures[0].ExpectedDeparture != "2014-03-10T12:37:00+01:00" || props.Departures[0].DelayMinutes != 3 {
+		t.Errorf("Unexpected properties %v", props)
+	}
+}
@@ -0,0 +1,81 @@
+package openapi
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+//gpxPoint is a waypoint or route point
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name,omitempty"`
+}
+
+//gpxRoute is an ordered list of points, i.e. one part of the path
+type gpxRoute struct {
+	Name   string     `xml:"name"`
+	Desc   string     `xml:"desc,omitempty"`
+	Type   string     `xml:"type,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+//gpxDocument is the root gpx element
+type gpxDocument struct {
+	XMLName   xml.Name   `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Routes    []gpxRoute `xml:"rte"`
+}
+
+//newGPXPoint returns the point at pos, a [lon, lat] pair, rounded to DefaultPrecision
+func newGPXPoint(pos [2]float64, name string) gpxPoint {
+	pos = roundPos(pos, DefaultPrecision)
+	return gpxPoint{pos[1], pos[0], name}
+}
+
+//WriteGPX writes GetJourneyPathResult as a GPX 1.1 document, with a
+//waypoint per stop and a route per part, typed by its Mode. Routes follow
+//the part's Geometry, across any gaps, and have no points without one.
+func (res GetJourneyPathResult) WriteGPX(w io.Writer) error {
+
+	parts, err := res.Parts()
+	if err != nil {
+		return err
+	}
+
+	doc := gpxDocument{Version: "1.1", Creator: "skanetrafiken"}
+
+	for _, s := range pathStops(parts) {
+		doc.Waypoints = append(doc.Waypoints, newGPXPoint(s.Pos(), s.Name))
+	}
+
+	for _, p := range parts {
+		rte := gpxRoute{Name: partName(p), Desc: p.From.Name + " – " + p.To.Name, Type: p.Mode()}
+		var lines [][][2]float64
+		switch g := p.Geometry().(type) {
+		case GeometryLineString:
+			lines = [][][2]float64{g.Coordinates}
+		case GeometryMultiLineString:
+			lines = g.Coordinates
+		}
+		for _, l := range lines {
+			for _, pos := range l {
+				rte.Points = append(rte.Points, newGPXPoint(pos, ""))
+			}
+		}
+		doc.Routes = append(doc.Routes, rte)
+	}
+
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err = enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
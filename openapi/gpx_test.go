@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+func TestWriteGPX(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := (GetJourneyPathResult{ResultXML: []byte(pathXML)}).WriteGPX(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		XMLName   xml.Name
+		Version   string     `xml:"version,attr"`
+		Waypoints []gpxPoint `xml:"wpt"`
+		Routes    []gpxRoute `xml:"rte"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.XMLName.Space != "http://www.topografix.com/GPX/1/1" || doc.Version != "1.1" {
+		t.Errorf("Unexpected document %v %s", doc.XMLName, doc.Version)
+	}
+	if len(doc.Waypoints) != 3 || doc.Waypoints[0].Name != "Malmö C" ||
+		doc.Waypoints[0].Lat != 55.608777 || doc.Waypoints[0].Lon != 13.0002 {
+		t.Errorf("Unexpected waypoints %v", doc.Waypoints)
+	}
+
+	//The missing coordinate of the bus is skipped
+	if len(doc.Routes) != 2 || doc.Routes[0].Type != ModeTrain || len(doc.Routes[0].Points) != 3 || len(doc.Routes[1].Points) != 4 {
+		t.Errorf("Unexpected routes %v", doc.Routes)
+	}
+}
+
+func TestWriteGPXUnlocated(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := (GetJourneyPathResult{ResultXML: []byte(unlocatedPathXML)}).WriteGPX(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Waypoints []gpxPoint `xml:"wpt"`
+		Routes    []gpxRoute `xml:"rte"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	//Like in KML, the walk is kept without points and only Malmö C is a waypoint
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Name != "Malmö C" {
+		t.Errorf("Unexpected waypoints %v", doc.Waypoints)
+	}
+	if len(doc.Routes) != 1 || doc.Routes[0].Type != ModeWalk || len(doc.Routes[0].Points) != 0 {
+		t.Errorf("Unexpected routes %v", doc.Routes)
+	}
+}
+
+func TestWriteGPXError(t *testing.T) {
+
+	res := GetJourneyPathResult{ResultXML: []byte("<Part><Line>")}
+	if err := res.WriteGPX(&bytes.Buffer{}); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("Expected ErrUnexpectedResponse, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+
+KML and GPX output of journey paths, for Google Earth and GPS units.
+
+*/
+
+package openapi
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//Transport modes of a Part, used as KML style ids and GPX types
+const (
+	ModeTrain = "train"
+	ModeBus   = "bus"
+	ModeTram  = "tram"
+	ModeFerry = "ferry"
+	ModeWalk  = "walk"
+	ModeOther = "other"
+)
+
+//Mode classifies the part by the line type name, e.g. "Pågatåg" is ModeTrain and "Gång" ModeWalk
+func (p Part) Mode() string {
+	name := strings.ToLower(p.Line.LinTName + " " + p.Line.Name)
+	switch {
+	case strings.Contains(name, "gång"):
+		return ModeWalk
+	case strings.Contains(name, "tåg"):
+		return ModeTrain
+	case strings.Contains(name, "buss"):
+		return ModeBus
+	case strings.Contains(name, "spårvagn"):
+		return ModeTram
+	case strings.Contains(name, "färja"), strings.Contains(name, "båt"):
+		return ModeFerry
+	}
+	return ModeOther
+}
+
+//kmlStyles are the line styles of each mode, colors are aabbggrr
+var kmlStyles = []kmlStyle{
+	{ModeTrain, kmlLineStyle{"ff7b2a8b", 4}},
+	{ModeBus, kmlLineStyle{"ff00b4f0", 4}},
+	{ModeTram, kmlLineStyle{"ff3c9a00", 4}},
+	{ModeFerry, kmlLineStyle{"ffcc6600", 4}},
+	{ModeWalk, kmlLineStyle{"ff808080", 2}},
+	{ModeOther, kmlLineStyle{"ff0000ff", 3}},
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+type kmlStyle struct {
+	Id        string       `xml:"id,attr"`
+	LineStyle kmlLineStyle `xml:"LineStyle"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlMultiGeometry struct {
+	LineStrings []kmlLineString `xml:"LineString"`
+}
+
+type kmlPlacemark struct {
+	Name          string            `xml:"name"`
+	Description   string            `xml:"description,omitempty"`
+	StyleUrl      string            `xml:"styleUrl,omitempty"`
+	Point         *kmlPoint         `xml:"Point,omitempty"`
+	LineString    *kmlLineString    `xml:"LineString,omitempty"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry,omitempty"`
+}
+
+type kmlDocument struct {
+	XMLName xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name    string         `xml:"Document>name"`
+	Styles  []kmlStyle     `xml:"Document>Style"`
+	Places  []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+//kmlCoordinates formats positions as KML "lon,lat" tuples
+func kmlCoordinates(pos ...[2]float64) string {
+	tuples := make([]string, len(pos))
+	for n, p := range pos {
+		p = roundPos(p, DefaultPrecision)
+		tuples[n] = strconv.FormatFloat(p[0], 'f', -1, 64) + "," + strconv.FormatFloat(p[1], 'f', -1, 64)
+	}
+	return strings.Join(tuples, " ")
+}
+
+//partName is the name of a part, e.g. "Öresundståg 1077"
+func partName(p Part) string {
+	if p.Line.No == 0 || strings.Contains(p.Line.Name, strconv.Itoa(p.Line.No)) {
+		return p.Line.Name
+	}
+	return fmt.Sprintf("%s %d", p.Line.Name, p.Line.No)
+}
+
+//pathStops returns the located From and To points of parts, without repeating the stop where parts meet
+func pathStops(parts []Part) []PartPoint {
+	var stops []PartPoint
+	for _, p := range parts {
+		for _, s := range []PartPoint{p.From, p.To} {
+			if s.X <= 0 || s.Y <= 0 {
+				continue
+			}
+			if n := len(stops); n > 0 && stops[n-1].Id == s.Id && stops[n-1].Name == s.Name {
+				continue
+			}
+			stops = append(stops, s)
+		}
+	}
+	return stops
+}
+
+//WriteKML writes GetJourneyPathResult as a KML document, with a LineString
+//placemark per part, styled by its Mode, and a Point placemark per stop
+func (res GetJourneyPathResult) WriteKML(w io.Writer) error {
+
+	parts, err := res.Parts()
+	if err != nil {
+		return err
+	}
+
+	doc := kmlDocument{Name: "Journey path", Styles: kmlStyles}
+
+	for _, p := range parts {
+		place := kmlPlacemark{
+			Name:        partName(p),
+			Description: p.From.Name + " – " + p.To.Name,
+			StyleUrl:    "#" + p.Mode(),
+		}
+		switch g := p.Geometry().(type) {
+		case GeometryLineString:
+			place.LineString = &kmlLineString{1, kmlCoordinates(g.Coordinates...)}
+		case GeometryMultiLineString:
+			place.MultiGeometry = &kmlMultiGeometry{}
+			for _, l := range g.Coordinates {
+				place.MultiGeometry.LineStrings = append(place.MultiGeometry.LineStrings, kmlLineString{1, kmlCoordinates(l...)})
+			}
+		}
+		doc.Places = append(doc.Places, place)
+	}
+
+	for _, s := range pathStops(parts) {
+		doc.Places = append(doc.Places, kmlPlacemark{Name: s.Name, Point: &kmlPoint{kmlCoordinates(s.Pos())}})
+	}
+
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err = enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,122 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPartMode(t *testing.T) {
+
+	tests := []struct {
+		line PartLine
+		want string
+	}{
+		{PartLine{Name: "Öresundståg", LinTName: "Öresundståg"}, ModeTrain},
+		{PartLine{Name: "Pågatåg", LinTName: "Pågatåg"}, ModeTrain},
+		{PartLine{Name: "Regionbuss 150", LinTName: "Regionbuss"}, ModeBus},
+		{PartLine{Name: "Gång", LinTName: "Gång"}, ModeWalk},
+		{PartLine{Name: "Ven-färjan", LinTName: "Färja"}, ModeFerry},
+		{PartLine{Name: "Spårvagn 1", LinTName: "Spårvagn"}, ModeTram},
+		{PartLine{Name: "Närtrafik", LinTName: "Anropsstyrd trafik"}, ModeOther},
+	}
+	for _, tt := range tests {
+		if m := (Part{Line: tt.line}).Mode(); m != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.line.Name, tt.want, m)
+		}
+
+		//Every mode has a style
+		styled := false
+		for _, st := range kmlStyles {
+			styled = styled || st.Id == tt.want
+		}
+		if !styled {
+			t.Errorf("No style for %s", tt.want)
+		}
+	}
+}
+
+func TestWriteKML(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := (GetJourneyPathResult{ResultXML: []byte(pathXML)}).WriteKML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Styles []struct {
+			Id string `xml:"id,attr"`
+		} `xml:"Document>Style"`
+		Places []struct {
+			Name          string   `xml:"name"`
+			StyleUrl      string   `xml:"styleUrl"`
+			LineString    string   `xml:"LineString>coordinates"`
+			MultiGeometry []string `xml:"MultiGeometry>LineString>coordinates"`
+			Point         string   `xml:"Point>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Styles) != len(kmlStyles) {
+		t.Errorf("Expected a style per mode, got %v", doc.Styles)
+	}
+
+	//Two parts and three stops, Landskrona is shared by both parts
+	if len(doc.Places) != 5 {
+		t.Fatalf("Expected 5 placemarks, got %d", len(doc.Places))
+	}
+	train, bus := doc.Places[0], doc.Places[1]
+	if train.Name != "Öresundståg 1077" || train.StyleUrl != "#train" || len(strings.Fields(train.LineString)) != 3 {
+		t.Errorf("Unexpected train placemark %v", train)
+	}
+	if !strings.HasPrefix(train.LineString, "13.0002,55.608777 ") {
+		t.Errorf("Expected lon,lat coordinates, got %s", train.LineString)
+	}
+	if bus.Name != "Buss 1" || bus.StyleUrl != "#bus" || len(bus.MultiGeometry) != 2 {
+		t.Errorf("Unexpected bus placemark %v", bus)
+	}
+	if stop := doc.Places[3]; stop.Name != "Landskrona" || stop.Point == "" {
+		t.Errorf("Unexpected stop placemark %v", stop)
+	}
+}
+
+//unlocatedPathXML is a walk to a stop without coordinates, and no path
+const unlocatedPathXML = `<Part><Line><Name>Gång</Name><LinTName>Gång</LinTName></Line>` +
+	`<From><Id>80000</Id><Name>Malmö C</Name><X>6167946</X><Y>1323245</Y></From>` +
+	`<To><Id>80001</Id><Name>Malmö Centralstationen</Name><X>0</X><Y>0</Y></To></Part>`
+
+func TestWriteKMLUnlocated(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := (GetJourneyPathResult{ResultXML: []byte(unlocatedPathXML)}).WriteKML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Places []struct {
+			Name       string `xml:"name"`
+			LineString string `xml:"LineString>coordinates"`
+			Point      string `xml:"Point>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	//The walk has no geometry, and only Malmö C gets a stop placemark
+	if len(doc.Places) != 2 || doc.Places[0].LineString != "" || doc.Places[1].Name != "Malmö C" {
+		t.Errorf("Unexpected placemarks %v", doc.Places)
+	}
+}
+
+func TestWriteKMLError(t *testing.T) {
+
+	res := GetJourneyPathResult{ResultXML: []byte("<Part><Line>")}
+	if err := res.WriteKML(&bytes.Buffer{}); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("Expected ErrUnexpectedResponse, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package openapi
+
+import (
+	"net/http"
+)
+
+//Option configures an OpenApi instance, see NewOpenAPI
+type Option func(*OpenApi)
+
+//WithBaseURL makes the instance target another server than BaseURL, e.g. a fixture server or a proxy
+func WithBaseURL(u string) Option {
+	return func(api *OpenApi) {
+		api.baseURL = u
+	}
+}
+
+//WithHTTPClient sets the http.Client used for all requests
+func WithHTTPClient(c *http.Client) Option {
+	return func(api *OpenApi) {
+		api.client = c
+	}
+}
+
+//WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(ua string) Option {
+	return func(api *OpenApi) {
+		api.userAgent = ua
+	}
+}
+
+//WithHeader adds a header that is sent with every request
+func WithHeader(key, value string) Option {
+	return func(api *OpenApi) {
+		if api.header == nil {
+			api.header = http.Header{}
+		}
+		api.header.Add(key, value)
+	}
+}
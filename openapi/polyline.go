@@ -0,0 +1,196 @@
+/*
+
+Google encoded polyline format for journey paths, see
+https://developers.google.com/maps/documentation/utilities/polylinealgorithm
+
+Precision 5 is what Google Maps uses, precision 6 what OSRM and Valhalla use.
+
+*/
+
+package openapi
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+//ErrInvalidPolyline is returned by DecodePolyline for malformed input
+var ErrInvalidPolyline = errors.New("invalid encoded polyline")
+
+//EncodePolyline encodes path with precision decimals
+func EncodePolyline(path []LatLon, precision int) string {
+
+	f := math.Pow10(precision)
+	var sb strings.Builder
+	var lat, lon int64
+
+	for _, p := range path {
+		nextLat, nextLon := int64(math.Round(p.Lat*f)), int64(math.Round(p.Lon*f))
+		encodePolylineValue(&sb, nextLat-lat)
+		encodePolylineValue(&sb, nextLon-lon)
+		lat, lon = nextLat, nextLon
+	}
+	return sb.String()
+}
+
+func encodePolylineValue(sb *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		sb.WriteByte(byte(0x20|u&0x1f) + 63)
+		u >>= 5
+	}
+	sb.WriteByte(byte(u) + 63)
+}
+
+//DecodePolyline decodes a path encoded with precision decimals
+func DecodePolyline(s string, precision int) ([]LatLon, error) {
+
+	f := math.Pow10(precision)
+	var path []LatLon
+	var lat, lon int64
+
+	for i := 0; i < len(s); {
+		var dlat, dlon int64
+		var err error
+		if dlat, i, err = decodePolylineValue(s, i); err != nil {
+			return nil, err
+		}
+		if dlon, i, err = decodePolylineValue(s, i); err != nil {
+			return nil, err
+		}
+		lat, lon = lat+dlat, lon+dlon
+		path = append(path, LatLon{float64(lat) / f, float64(lon) / f})
+	}
+	return path, nil
+}
+
+func decodePolylineValue(s string, i int) (int64, int, error) {
+	var u uint64
+	for shift := uint(0); ; shift += 5 {
+		if i == len(s) || shift > 60 {
+			return 0, i, ErrInvalidPolyline
+		}
+		b := s[i]
+		if b < 63 || b > 63+0x3f {
+			return 0, i, ErrInvalidPolyline
+		}
+		i++
+		u |= (uint64(b-63) & 0x1f) << shift
+		if b-63 < 0x20 {
+			break
+		}
+	}
+	v := int64(u >> 1)
+	if u&1 != 0 {
+		v = ^v
+	}
+	return v, i, nil
+}
+
+/*
+Simplify removes points of path that are closer than tolerance meters to
+the line through their neighbours, with the Douglas–Peucker algorithm.
+The first and last points are always kept.
+*/
+func Simplify(path []LatLon, tolerance float64) []LatLon {
+	if len(path) < 3 || tolerance <= 0 {
+		return path
+	}
+
+	keep := make([]bool, len(path))
+	keep[0], keep[len(path)-1] = true, true
+	simplify(path, keep, 0, len(path)-1, tolerance)
+
+	var simplified []LatLon
+	for n, p := range path {
+		if keep[n] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+func simplify(path []LatLon, keep []bool, first, last int, tolerance float64) {
+	max, index := 0.0, 0
+	for n := first + 1; n < last; n++ {
+		if d := segmentDistance(path[n], path[first], path[last]); d > max {
+			max, index = d, n
+		}
+	}
+	if max > tolerance {
+		keep[index] = true
+		simplify(path, keep, first, index, tolerance)
+		simplify(path, keep, index, last, tolerance)
+	}
+}
+
+//segmentDistance is the distance (in meters) from p to the segment a-b, on a
+//local equirectangular projection, which is accurate for short segments
+func segmentDistance(p, a, b LatLon) float64 {
+	k := math.Cos(radians(a.Lat)) * EarthRadius
+	xy := func(q LatLon) (float64, float64) {
+		return radians(q.Lon-a.Lon) * k, radians(q.Lat-a.Lat) * EarthRadius
+	}
+	px, py := xy(p)
+	bx, by := xy(b)
+
+	t := 0.0
+	if l := bx*bx + by*by; l > 0 {
+		t = math.Max(0, math.Min(1, (px*bx+py*by)/l))
+	}
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+//Path returns the WGS84 path of the part, without missing coordinates
+func (p Part) Path() []LatLon {
+	var path []LatLon
+	for _, c := range p.Coords {
+		if c.X > 0 && c.Y > 0 {
+			path = append(path, c.ToWGS84())
+		}
+	}
+	return path
+}
+
+//Polyline encodes the path of the part with precision decimals, simplified to tolerance meters unless it is 0
+func (p Part) Polyline(precision int, tolerance float64) string {
+	return EncodePolyline(Simplify(p.Path(), tolerance), precision)
+}
+
+//Polylines encodes the path of each part, see Part.Polyline
+func (res GetJourneyPathResult) Polylines(precision int, tolerance float64) ([]string, error) {
+
+	parts, err := res.Parts()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, len(parts))
+	for n, p := range parts {
+		lines[n] = p.Polyline(precision, tolerance)
+	}
+	return lines, nil
+}
+
+//Polyline encodes the whole path as one line, where parts meet only once
+func (res GetJourneyPathResult) Polyline(precision int, tolerance float64) (string, error) {
+
+	parts, err := res.Parts()
+	if err != nil {
+		return "", err
+	}
+
+	var path []LatLon
+	for _, p := range parts {
+		pp := p.Path()
+		if len(path) > 0 && len(pp) > 0 && path[len(path)-1] == pp[0] {
+			pp = pp[1:]
+		}
+		path = append(path, pp...)
+	}
+	return EncodePolyline(Simplify(path, tolerance), precision), nil
+}
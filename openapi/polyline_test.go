@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+//googlePath is the example of the polyline algorithm documentation
+var googlePath = []LatLon{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}
+
+func TestEncodePolyline(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		path      []LatLon
+		precision int
+		want      string
+	}{
+		{"empty", nil, 5, ""},
+		{"google", googlePath, 5, "_p~iF~ps|U_ulLnnqC_mqNvxq`@"},
+		{"google precision 6", googlePath, 6, "_izlhA~rlgdF_{geC~ywl@_kwzCn`{nI"},
+		{"single point", []LatLon{{55.6, 13.0}}, 5, "_kjrI_ajnA"},
+	}
+	for _, tt := range tests {
+		if s := EncodePolyline(tt.path, tt.precision); s != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, s)
+		}
+	}
+}
+
+func TestDecodePolyline(t *testing.T) {
+
+	for _, precision := range []int{5, 6} {
+		path, err := DecodePolyline(EncodePolyline(googlePath, precision), precision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(path) != len(googlePath) {
+			t.Fatalf("Expected %d points, got %d", len(googlePath), len(path))
+		}
+		for n, p := range path {
+			if math.Abs(p.Lat-googlePath[n].Lat) > 1e-9 || math.Abs(p.Lon-googlePath[n].Lon) > 1e-9 {
+				t.Errorf("Precision %d: expected %s, got %s", precision, googlePath[n], p)
+			}
+		}
+	}
+
+	for _, s := range []string{"_p~iF~ps|U_", "_p~iF", "_p~iF ps|U", "\x7f\x7f"} {
+		if _, err := DecodePolyline(s, 5); !errors.Is(err, ErrInvalidPolyline) {
+			t.Errorf("%q: expected ErrInvalidPolyline, got %v", s, err)
+		}
+	}
+}
+
+func TestSimplify(t *testing.T) {
+
+	// About 111 m between points, the middle one 13 m off the line, so
+	// its neighbours are 6 m off the lines to it
+	path := []LatLon{{55.600, 13.000}, {55.601, 13.000}, {55.602, 13.0002}, {55.603, 13.000}, {55.604, 13.000}}
+
+	tests := []struct {
+		tolerance float64
+		want      int
+	}{
+		{0, 5},
+		{1, 5},
+		{10, 3},
+		{20, 2},
+	}
+	for _, tt := range tests {
+		if s := Simplify(path, tt.tolerance); len(s) != tt.want || s[0] != path[0] || s[len(s)-1] != path[4] {
+			t.Errorf("Tolerance %.0f: expected %d points, got %v", tt.tolerance, tt.want, s)
+		}
+	}
+}
+
+func TestJourneyPathPolyline(t *testing.T) {
+
+	res := GetJourneyPathResult{ResultXML: []byte(pathXML)}
+
+	lines, err := res.Polylines(5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected a polyline per part, got %d", len(lines))
+	}
+
+	//The missing coordinate of the bus is skipped
+	if path, _ := DecodePolyline(lines[1], 5); len(path) != 4 {
+		t.Errorf("Expected 4 points, got %v", path)
+	}
+
+	line, err := res.Polyline(6, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, _ := DecodePolyline(line, 6)
+	if len(path) != 6 || path[0] != (LatLon{55.608777, 13.0002}) {
+		t.Errorf("Expected the parts joined at Landskrona, got %v", path)
+	}
+
+	if _, err = (GetJourneyPathResult{ResultXML: []byte("<Part>")}).Polyline(5, 0); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("Expected ErrUnexpectedResponse, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package openapi
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+Projection is a Gauss-Krüger (transverse Mercator) grid on the GRS80
+ellipsoid. The RT90 parameter sets are adjusted so that they convert
+directly between RT90 and WGS84, without a datum shift.
+*/
+type Projection struct {
+	Name            string
+	EPSG            int
+	CentralMeridian float64
+	Scale           float64
+	FalseNorthing   float64
+	FalseEasting    float64
+}
+
+//RT90 projections, RT90_2_5_GON_V is the one used by the Open API
+var (
+	RT90_7_5_GON_V = Projection{"rt90_7.5_gon_v", 3019, 11.0 + 18.375/60.0, 1.000006000000, -667.282, 1500025.141}
+	RT90_5_0_GON_V = Projection{"rt90_5.0_gon_v", 3020, 13.0 + 33.376/60.0, 1.000005800000, -667.130, 1500044.695}
+	RT90_2_5_GON_V = Projection{"rt90_2.5_gon_v", 3021, CentralMeridian, Scale, FalseNorthing, FalseEasting}
+	RT90_0_0_GON_V = Projection{"rt90_0.0_gon_v", 3022, 18.0 + 3.378/60.0, 1.000005400000, -668.844, 1500083.521}
+	RT90_2_5_GON_O = Projection{"rt90_2.5_gon_o", 3023, 20.0 + 18.379/60.0, 1.000005200000, -670.706, 1500102.765}
+	RT90_5_0_GON_O = Projection{"rt90_5.0_gon_o", 3024, 22.0 + 33.380/60.0, 1.000004900000, -672.557, 1500121.846}
+)
+
+//SWEREF 99 projections, SWEREF99_TM for the whole country and local zones named by their central meridian
+var (
+	SWEREF99_TM   = Projection{"sweref_99_tm", 3006, 15.0, 0.9996, 0.0, 500000.0}
+	SWEREF99_1200 = Projection{"sweref_99_1200", 3007, 12.00, 1.0, 0.0, 150000.0}
+	SWEREF99_1330 = Projection{"sweref_99_1330", 3008, 13.50, 1.0, 0.0, 150000.0}
+	SWEREF99_1500 = Projection{"sweref_99_1500", 3009, 15.00, 1.0, 0.0, 150000.0}
+	SWEREF99_1630 = Projection{"sweref_99_1630", 3010, 16.50, 1.0, 0.0, 150000.0}
+	SWEREF99_1800 = Projection{"sweref_99_1800", 3011, 18.00, 1.0, 0.0, 150000.0}
+	SWEREF99_1415 = Projection{"sweref_99_1415", 3012, 14.25, 1.0, 0.0, 150000.0}
+	SWEREF99_1545 = Projection{"sweref_99_1545", 3013, 15.75, 1.0, 0.0, 150000.0}
+	SWEREF99_1715 = Projection{"sweref_99_1715", 3014, 17.25, 1.0, 0.0, 150000.0}
+	SWEREF99_1845 = Projection{"sweref_99_1845", 3015, 18.75, 1.0, 0.0, 150000.0}
+	SWEREF99_2015 = Projection{"sweref_99_2015", 3016, 20.25, 1.0, 0.0, 150000.0}
+	SWEREF99_2145 = Projection{"sweref_99_2145", 3017, 21.75, 1.0, 0.0, 150000.0}
+	SWEREF99_2315 = Projection{"sweref_99_2315", 3018, 23.25, 1.0, 0.0, 150000.0}
+)
+
+//Projections lists all predefined projections
+var Projections = []Projection{
+	RT90_7_5_GON_V, RT90_5_0_GON_V, RT90_2_5_GON_V, RT90_0_0_GON_V, RT90_2_5_GON_O, RT90_5_0_GON_O,
+	SWEREF99_TM, SWEREF99_1200, SWEREF99_1330, SWEREF99_1500, SWEREF99_1630, SWEREF99_1800,
+	SWEREF99_1415, SWEREF99_1545, SWEREF99_1715, SWEREF99_1845, SWEREF99_2015, SWEREF99_2145, SWEREF99_2315,
+}
+
+//ProjectionByName finds a predefined projection by name, e.g. "sweref_99_tm", or EPSG code, e.g. "EPSG:3006"
+func ProjectionByName(name string) (Projection, bool) {
+	for _, p := range Projections {
+		if strings.EqualFold(name, p.Name) || strings.EqualFold(name, p.String()) {
+			return p, true
+		}
+	}
+	return Projection{}, false
+}
+
+//String returns the EPSG code of the projection
+func (p Projection) String() string {
+	return "EPSG:" + strconv.Itoa(p.EPSG)
+}
+
+//Reproject converts grid coordinates (northing x, easting y) from one projection to another
+func Reproject(from, to Projection, x, y float64) (float64, float64) {
+	return to.ToGrid(from.ToGeodetic(x, y))
+}
+
+//ToGeodetic converts grid coordinates (northing x, easting y) to WGS84
+func (p Projection) ToGeodetic(x, y float64) (float64, float64) {
+
+	e2 := Flattening * (2.0 - Flattening)
+	n := Flattening / (2.0 - Flattening)
+	a_roof := Axis / (1.0 + n) * (1.0 + n*n/4.0 + n*n*n*n/64.0)
+	delta1 := n/2.0 - 2.0*n*n/3.0 + 37.0*n*n*n/96.0 - n*n*n*n/360.0
+	delta2 := n*n/48.0 + n*n*n/15.0 - 437.0*n*n*n*n/1440.0
+	delta3 := 17.0*n*n*n/480.0 - 37*n*n*n*n/840.0
+	delta4 := 4397.0 * n * n * n * n / 161280.0
+
+	Astar := e2 + e2*e2 + e2*e2*e2 + e2*e2*e2*e2
+	Bstar := -(7.0*e2*e2 + 17.0*e2*e2*e2 + 30.0*e2*e2*e2*e2) / 6.0
+	Cstar := (224.0*e2*e2*e2 + 889.0*e2*e2*e2*e2) / 120.0
+	Dstar := -(4279.0 * e2 * e2 * e2 * e2) / 1260.0
+
+	DegToRad := math.Pi / 180
+	LambdaZero := p.CentralMeridian * DegToRad
+	xi := (x - p.FalseNorthing) / (p.Scale * a_roof)
+	eta := (y - p.FalseEasting) / (p.Scale * a_roof)
+	xi_prim := xi - delta1*math.Sin(2.0*xi)*math.Cosh(2.0*eta) - delta2*math.Sin(4.0*xi)*math.Cosh(4.0*eta) - delta3*math.Sin(6.0*xi)*math.Cosh(6.0*eta) - delta4*math.Sin(8.0*xi)*math.Cosh(8.0*eta)
+	eta_prim := eta - delta1*math.Cos(2.0*xi)*math.Sinh(2.0*eta) - delta2*math.Cos(4.0*xi)*math.Sinh(4.0*eta) - delta3*math.Cos(6.0*xi)*math.Sinh(6.0*eta) - delta4*math.Cos(8.0*xi)*math.Sinh(8.0*eta)
+	phi_star := math.Asin(math.Sin(xi_prim) / math.Cosh(eta_prim))
+	delta_lambda := math.Atan(math.Sinh(eta_prim) / math.Cos(xi_prim))
+	lon_radian := LambdaZero + delta_lambda
+	lat_radian := phi_star + math.Sin(phi_star)*math.Cos(phi_star)*(Astar+Bstar*math.Pow(math.Sin(phi_star), 2)+Cstar*math.Pow(math.Sin(phi_star), 4)+Dstar*math.Pow(math.Sin(phi_star), 6))
+
+	return lat_radian * 180.0 / math.Pi, lon_radian * 180.0 / math.Pi
+}
+
+//ToGrid converts WGS84 coordinates to grid coordinates (northing x, easting y)
+func (p Projection) ToGrid(lat, lon float64) (x, y float64) {
+
+	// Prepare ellipsoid-based stuff.
+	e2 := Flattening * (2.0 - Flattening)
+	n := Flattening / (2.0 - Flattening)
+	a_roof := Axis / (1.0 + n) * (1.0 + n*n/4.0 + n*n*n*n/64.0)
+	A := e2
+	B := (5.0*e2*e2 - e2*e2*e2) / 6.0
+	C := (104.0*e2*e2*e2 - 45.0*e2*e2*e2*e2) / 120.0
+	D := (1237.0 * e2 * e2 * e2 * e2) / 1260.0
+	beta1 := n/2.0 - 2.0*n*n/3.0 + 5.0*n*n*n/16.0 + 41.0*n*n*n*n/180.0
+	beta2 := 13.0*n*n/48.0 - 3.0*n*n*n/5.0 + 557.0*n*n*n*n/1440.0
+	beta3 := 61.0*n*n*n/240.0 - 103.0*n*n*n*n/140.0
+	beta4 := 49561.0 * n * n * n * n / 161280.0
+
+	// Convert.
+	DegToRad := math.Pi / 180.0
+	phi := lat * DegToRad
+	lambd := lon * DegToRad
+	lambda_zero := p.CentralMeridian * DegToRad
+
+	phi_star := phi - math.Sin(phi)*math.Cos(phi)*(A+
+		B*math.Pow(math.Sin(phi), 2)+
+		C*math.Pow(math.Sin(phi), 4)+
+		D*math.Pow(math.Sin(phi), 6))
+	delta_lambda := lambd - lambda_zero
+	xi_prim := math.Atan(math.Tan(phi_star) / math.Cos(delta_lambda))
+	eta_prim := math.Atanh(math.Cos(phi_star) * math.Sin(delta_lambda))
+	x = p.Scale*a_roof*(xi_prim+
+		beta1*math.Sin(2.0*xi_prim)*math.Cosh(2.0*eta_prim)+
+		beta2*math.Sin(4.0*xi_prim)*math.Cosh(4.0*eta_prim)+
+		beta3*math.Sin(6.0*xi_prim)*math.Cosh(6.0*eta_prim)+
+		beta4*math.Sin(8.0*xi_prim)*math.Cosh(8.0*eta_prim)) +
+		p.FalseNorthing
+	y = p.Scale*a_roof*(eta_prim+
+		beta1*math.Cos(2.0*xi_prim)*math.Sinh(2.0*eta_prim)+
+		beta2*math.Cos(4.0*xi_prim)*math.Sinh(4.0*eta_prim)+
+		beta3*math.Cos(6.0*xi_prim)*math.Sinh(6.0*eta_prim)+
+		beta4*math.Cos(8.0*xi_prim)*math.Sinh(8.0*eta_prim)) +
+		p.FalseEasting
+	return x, y
+}
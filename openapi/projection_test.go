@@ -0,0 +1,81 @@
+package openapi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProjectionCentralMeridian(t *testing.T) {
+
+	// On the central meridian the northing is the scaled GRS80 meridian arc
+	// length, 6654072.819 m at 60N, and the easting is the false easting
+	tests := []struct {
+		p    Projection
+		x, y float64
+	}{
+		{SWEREF99_TM, 0.9996 * 6654072.819, 500000},
+		{SWEREF99_1500, 6654072.819, 150000},
+		{SWEREF99_1800, 6654072.819, 150000},
+	}
+	for _, tt := range tests {
+		x, y := tt.p.ToGrid(60, tt.p.CentralMeridian)
+		if math.Abs(x-tt.x) > 0.001 || math.Abs(y-tt.y) > 0.001 {
+			t.Errorf("%s: expected %.3f,%.3f, got %.3f,%.3f", tt.p.Name, tt.x, tt.y, x, y)
+		}
+	}
+}
+
+func TestProjectionRoundTrip(t *testing.T) {
+
+	// Malmö and Kiruna, within and far outside the zone of most projections
+	points := [][2]float64{{55.519919, 12.997947}, {67.855800, 20.225282}}
+
+	for _, p := range Projections {
+		for _, pt := range points {
+			lat, lon := p.ToGeodetic(p.ToGrid(pt[0], pt[1]))
+			if math.Abs(lat-pt[0]) > 1e-8 || math.Abs(lon-pt[1]) > 1e-8 {
+				t.Errorf("%s: expected %f,%f, got %f,%f", p.Name, pt[0], pt[1], lat, lon)
+			}
+		}
+	}
+}
+
+func TestReproject(t *testing.T) {
+
+	x, y := Reproject(RT90_2_5_GON_V, SWEREF99_TM, 6158063, 1322703)
+	if math.Abs(x-6154470.6) > 0.1 || math.Abs(y-373602.2) > 0.1 {
+		t.Errorf("Unexpected SWEREF 99 TM %.1f,%.1f", x, y)
+	}
+
+	x, y = Reproject(SWEREF99_TM, RT90_2_5_GON_V, x, y)
+	if math.Abs(x-6158063) > 0.001 || math.Abs(y-1322703) > 0.001 {
+		t.Errorf("Unexpected RT90 %.3f,%.3f", x, y)
+	}
+
+	// The RT90 default matches the package level conversion
+	x1, y1 := GeodeticToGrid(55.519919, 12.997947)
+	x2, y2 := RT90_2_5_GON_V.ToGrid(55.519919, 12.997947)
+	if x1 != x2 || y1 != y2 {
+		t.Error("GeodeticToGrid does not match RT90_2_5_GON_V")
+	}
+}
+
+func TestProjectionByName(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want Projection
+		ok   bool
+	}{
+		{"sweref_99_tm", SWEREF99_TM, true},
+		{"EPSG:3006", SWEREF99_TM, true},
+		{"epsg:3021", RT90_2_5_GON_V, true},
+		{"RT90_5.0_GON_O", RT90_5_0_GON_O, true},
+		{"utm33", Projection{}, false},
+	}
+	for _, tt := range tests {
+		if p, ok := ProjectionByName(tt.name); p != tt.want || ok != tt.ok {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, p)
+		}
+	}
+}
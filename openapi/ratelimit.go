@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrRateLimited is returned in RateLimitFailFast mode when no request may be made right now
+var ErrRateLimited = errors.New("rate limited")
+
+//RateLimitMode decides what happens when the rate limit is reached
+type RateLimitMode int
+
+const (
+	//RateLimitBlock waits for a free slot, or until the context is done
+	RateLimitBlock RateLimitMode = iota
+	//RateLimitFailFast returns ErrRateLimited at once
+	RateLimitFailFast
+)
+
+//rateLimits holds the token buckets of an OpenApi instance
+type rateLimits struct {
+	mode      RateLimitMode
+	global    *tokenBucket
+	endpoints map[string]*tokenBucket
+}
+
+func (api *OpenApi) rateLimits() *rateLimits {
+	if api.limits == nil {
+		api.limits = &rateLimits{endpoints: make(map[string]*tokenBucket)}
+	}
+	return api.limits
+}
+
+/*
+WithRateLimit throttles all upstream requests to rate requests per second,
+allowing bursts of up to burst requests. Every attempt counts, including
+retries, but cache hits and coalesced requests do not.
+*/
+func WithRateLimit(rate float64, burst int) Option {
+	return func(api *OpenApi) {
+		api.rateLimits().global = newTokenBucket(rate, burst)
+	}
+}
+
+//WithEndpointRateLimit overrides the rate limit for one endpoint, e.g. RESULTSPAGE
+func WithEndpointRateLimit(endpoint string, rate float64, burst int) Option {
+	return func(api *OpenApi) {
+		api.rateLimits().endpoints[endpoint] = newTokenBucket(rate, burst)
+	}
+}
+
+//WithRateLimitMode sets whether requests over the limit block or fail fast
+func WithRateLimitMode(mode RateLimitMode) Option {
+	return func(api *OpenApi) {
+		api.rateLimits().mode = mode
+	}
+}
+
+//wait blocks until a request to endpoint is allowed
+func (l *rateLimits) wait(ctx context.Context, endpoint string) error {
+	b, ok := l.endpoints[endpoint]
+	if !ok {
+		b = l.global
+	}
+	if b == nil {
+		return nil
+	}
+
+	if l.mode == RateLimitFailFast {
+		if !b.allow() {
+			return &APIError{Endpoint: endpoint, Err: ErrRateLimited}
+		}
+		return nil
+	}
+	return b.wait(ctx)
+}
+
+//tokenBucket is a token bucket that refills at rate tokens per second, up to burst
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), now: time.Now}
+}
+
+//refill adds the tokens accumulated since the last call, must be called with mu held
+func (b *tokenBucket) refill() {
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+//allow takes a token if one is available
+func (b *tokenBucket) allow() bool {
+	if b.rate <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+//reserve takes a token, possibly going into debt, and returns how long to wait until it is valid
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+//cancel gives back a reserved token that was never used
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+//wait takes a token, blocking until it is valid or ctx is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return nil
+	}
+
+	d := b.reserve()
+	if d == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		b.cancel()
+		return context.DeadlineExceeded
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,103 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+
+	now := time.Date(2014, 3, 10, 12, 0, 0, 0, time.UTC)
+	b := newTokenBucket(2, 3)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !b.allow() {
+			t.Errorf("Burst request %d should be allowed", i)
+		}
+	}
+	if b.allow() {
+		t.Error("Request over burst should not be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !b.allow() {
+		t.Error("One token should have been refilled")
+	}
+
+	if d := b.reserve(); d != 500*time.Millisecond {
+		t.Errorf("Expected to wait 500ms, got %v", d)
+	}
+}
+
+func TestTokenBucketWaitCanceled(t *testing.T) {
+
+	b := newTokenBucket(1, 1)
+	b.allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if b.tokens < -0.1 {
+		t.Errorf("Canceled wait should give back its token, have %f", b.tokens)
+	}
+}
+
+func TestRateLimitFailFast(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/querystation.asp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	api := NewOpenAPI(WithBaseURL(srv.URL),
+		WithRateLimit(1000, 10),
+		WithEndpointRateLimit(QUERYSTATION, 0.001, 2),
+		WithRateLimitMode(RateLimitFailFast))
+
+	for i := 0; i < 2; i++ {
+		if _, err := api.QueryStation("Malmö"); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := api.QueryStation("Malmö"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestRateLimitBlock(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/querystation.asp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	api := NewOpenAPI(WithBaseURL(srv.URL), WithRateLimit(50, 1))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := api.QueryStation("Malmö"); err != nil {
+			t.Error(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("Expected requests to be throttled, took %v", elapsed)
+	}
+}
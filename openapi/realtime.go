@@ -0,0 +1,123 @@
+/*
+Methods that combine scheduled times with the deviations in RealTimeInfo.
+
+Deviations are given in minutes by the Open API, positive when late.
+*/
+
+package openapi
+
+import (
+	"time"
+)
+
+//IsDelayed tells if departure or arrival is later than scheduled
+func (r RealTimeInfo) IsDelayed() bool {
+	return r.DepTimeDeviation > 0 || r.ArrTimeDeviation > 0
+}
+
+//IsCanceled tells if the departure is canceled
+func (r RealTimeInfo) IsCanceled() bool {
+	return r.Canceled
+}
+
+//deviate adds minutes to t, unless t is zero
+func deviate(t SkanetrafikenTime, minutes int) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(minutes) * time.Minute)
+}
+
+//ExpectedDeparture returns the departure time adjusted for real-time deviation
+func (l Line) ExpectedDeparture() time.Time {
+	return deviate(l.JourneyDateTime, l.RealTime.DepTimeDeviation)
+}
+
+//DelayMinutes returns the departure delay in minutes, negative if early
+func (l Line) DelayMinutes() int {
+	return l.RealTime.DepTimeDeviation
+}
+
+//IsDelayed tells if the line departs later than scheduled
+func (l Line) IsDelayed() bool {
+	return l.RealTime.DepTimeDeviation > 0
+}
+
+//IsCanceled tells if the departure is canceled
+func (l Line) IsCanceled() bool {
+	return l.RealTime.Canceled
+}
+
+//ExpectedDeparture returns the departure time adjusted for real-time deviation
+func (r RouteLink) ExpectedDeparture() time.Time {
+	return deviate(r.DepDateTime, r.RealTime.DepTimeDeviation)
+}
+
+//ExpectedArrival returns the arrival time adjusted for real-time deviation
+func (r RouteLink) ExpectedArrival() time.Time {
+	return deviate(r.ArrDateTime, r.RealTime.ArrTimeDeviation)
+}
+
+//IsDelayed tells if the route link departs or arrives later than scheduled
+func (r RouteLink) IsDelayed() bool {
+	return r.RealTime.IsDelayed()
+}
+
+//IsCanceled tells if the route link is canceled
+func (r RouteLink) IsCanceled() bool {
+	return r.RealTime.Canceled
+}
+
+//ExpectedDeparture returns the departure of the first route link, adjusted for real-time deviation
+func (j Journey) ExpectedDeparture() time.Time {
+	if len(j.RouteLinks) == 0 {
+		return j.DepDateTime.Time
+	}
+	return j.RouteLinks[0].ExpectedDeparture()
+}
+
+//ExpectedArrival returns the arrival of the last route link, adjusted for real-time deviation
+func (j Journey) ExpectedArrival() time.Time {
+	if len(j.RouteLinks) == 0 {
+		return j.ArrDateTime.Time
+	}
+	return j.RouteLinks[len(j.RouteLinks)-1].ExpectedArrival()
+}
+
+//ScheduledDuration returns the travel time according to the timetable
+func (j Journey) ScheduledDuration() time.Duration {
+	return j.ArrDateTime.Sub(j.DepDateTime.Time)
+}
+
+//ExpectedDuration returns the travel time adjusted for real-time deviations
+func (j Journey) ExpectedDuration() time.Duration {
+	return j.ExpectedArrival().Sub(j.ExpectedDeparture())
+}
+
+//ArrDelayMinutes returns how many minutes later than scheduled the journey arrives
+func (j Journey) ArrDelayMinutes() int {
+	if j.ArrDateTime.IsZero() {
+		return 0
+	}
+	return int(j.ExpectedArrival().Sub(j.ArrDateTime.Time) / time.Minute)
+}
+
+//IsDelayed tells if any route link of the journey is delayed
+func (j Journey) IsDelayed() bool {
+	for _, r := range j.RouteLinks {
+		if r.IsDelayed() {
+			return true
+		}
+	}
+	return false
+}
+
+//IsCanceled tells if any route link of the journey is canceled
+func (j Journey) IsCanceled() bool {
+	for _, r := range j.RouteLinks {
+		if r.IsCanceled() {
+			return true
+		}
+	}
+	return false
+}
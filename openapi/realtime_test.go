@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func mustParseTime(s string) SkanetrafikenTime {
+	t, err := ParseSkanetrafikenTime(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func testJourney() Journey {
+	return Journey{
+		DepDateTime: mustParseTime("2014-03-10T12:34:00"),
+		ArrDateTime: mustParseTime("2014-03-10T13:20:00"),
+		RouteLinks: []RouteLink{
+			{
+				DepDateTime: mustParseTime("2014-03-10T12:34:00"),
+				ArrDateTime: mustParseTime("2014-03-10T12:50:00"),
+				RealTime:    RealTimeInfo{DepTimeDeviation: 2, ArrTimeDeviation: 1},
+			},
+			{
+				DepDateTime: mustParseTime("2014-03-10T13:00:00"),
+				ArrDateTime: mustParseTime("2014-03-10T13:20:00"),
+				RealTime:    RealTimeInfo{ArrTimeDeviation: 5},
+			},
+		},
+	}
+}
+
+func TestLineExpectedDeparture(t *testing.T) {
+
+	l := Line{JourneyDateTime: mustParseTime("2014-03-10T12:34:00"), RealTime: RealTimeInfo{DepTimeDeviation: 3}}
+
+	if !l.ExpectedDeparture().Equal(mustParseTime("2014-03-10T12:37:00").Time) {
+		t.Errorf("Unexpected departure %v", l.ExpectedDeparture())
+	}
+	if !l.IsDelayed() || l.IsCanceled() || l.DelayMinutes() != 3 {
+		t.Error("Line should be delayed 3 minutes, not canceled")
+	}
+
+	if !(Line{}).ExpectedDeparture().IsZero() {
+		t.Error("Missing departure should stay zero")
+	}
+}
+
+func TestJourneyExpected(t *testing.T) {
+
+	j := testJourney()
+
+	if j.ScheduledDuration() != 46*time.Minute {
+		t.Errorf("Unexpected scheduled duration %v", j.ScheduledDuration())
+	}
+	if j.ExpectedDuration() != 49*time.Minute {
+		t.Errorf("Unexpected expected duration %v", j.ExpectedDuration())
+	}
+	if j.ArrDelayMinutes() != 5 || !j.IsDelayed() || j.IsCanceled() {
+		t.Error("Journey should arrive 5 minutes late")
+	}
+
+	j.RouteLinks[1].RealTime.Canceled = true
+	if !j.IsCanceled() {
+		t.Error("Journey with canceled route link should be canceled")
+	}
+}
+
+func TestJourneyWriteJSON(t *testing.T) {
+
+	var buf bytes.Buffer
+	res := GetJourneyResult{JourneyResultKey: "abc", Journeys: []Journey{testJourney()}}
+	if err := res.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		JourneyResultKey string
+		Journeys         []struct {
+			DepDateTime      string
+			ExpectedArrival  string
+			ExpectedDuration int
+			DelayMinutes     int
+			RouteLinks       []struct {
+				ExpectedDeparture string
+				DepDelayMinutes   int
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.JourneyResultKey != "abc" || len(out.Journeys) != 1 {
+		t.Fatalf("Unexpected JSON %s", buf.String())
+	}
+	j := out.Journeys[0]
+	if j.DepDateTime != "2014-03-10T12:34:00+01:00" || j.ExpectedArrival != "2014-03-10T13:25:00+01:00" ||
+		j.ExpectedDuration != 49 || j.DelayMinutes != 5 {
+		t.Errorf("Unexpected journey %+v", j)
+	}
+	if len(j.RouteLinks) != 2 || j.RouteLinks[0].ExpectedDeparture != "2014-03-10T12:36:00+01:00" || j.RouteLinks[0].DepDelayMinutes != 2 {
+		t.Errorf("Unexpected route links %+v", j.RouteLinks)
+	}
+}
+
+func TestDepartureWriteJSON(t *testing.T) {
+
+	var buf bytes.Buffer
+	res := GetDepartureArrivalResult{Lines: []Line{
+		{Name: "Stadsbuss 3", JourneyDateTime: mustParseTime("2014-03-10T12:40:00"), RealTime: RealTimeInfo{DepTimeDeviation: 4}},
+	}}
+	if err := res.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []struct {
+		Name              string
+		ExpectedDeparture string
+		DelayMinutes      int
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Name != "Stadsbuss 3" || out[0].ExpectedDeparture != "2014-03-10T12:44:00+01:00" || out[0].DelayMinutes != 4 {
+		t.Errorf("Unexpected JSON %s", buf.String())
+	}
+}
@@ -0,0 +1,127 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+/*
+RetryPolicy makes the OpenApi retry transient failures with exponential
+backoff and jitter. All endpoint methods share the instance's policy.
+
+The delay before attempt n+1 is BaseDelay * 2^(n-1), capped at MaxDelay,
+of which the fraction Jitter is randomized. A Retry-After header from
+upstream is honoured when it asks for a longer wait.
+*/
+type RetryPolicy struct {
+	MaxAttempts        int
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	Jitter             float64
+	RetryStatuses      []int
+	RetryNetworkErrors bool
+
+	//OnAttempt, if set, is called after every attempt
+	OnAttempt func(Attempt)
+}
+
+//Attempt describes one try made under a RetryPolicy
+type Attempt struct {
+	Endpoint string
+	Number   int
+	Err      error
+	//Delay is the wait before the next attempt, zero if there is none
+	Delay time.Duration
+}
+
+//DefaultRetryPolicy retries network errors, 429 and 5xx gateway errors up to three times
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      0.5,
+	RetryStatuses: []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	RetryNetworkErrors: true,
+}
+
+//WithRetry sets the RetryPolicy, see DefaultRetryPolicy
+func WithRetry(p RetryPolicy) Option {
+	return func(api *OpenApi) {
+		api.retry = &p
+	}
+}
+
+//retryable tells if err is worth another attempt
+func (p *RetryPolicy) retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.HTTPStatus == 0 {
+		return p.RetryNetworkErrors && errors.Is(apiErr, ErrUpstreamUnavailable)
+	}
+	for _, s := range p.RetryStatuses {
+		if s == apiErr.HTTPStatus {
+			return true
+		}
+	}
+	return false
+}
+
+//backoff returns the delay after attempt n (starting at 1)
+func (p *RetryPolicy) backoff(n int, err error) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		j := time.Duration(float64(d) * p.Jitter)
+		d = d - j + time.Duration(rand.Int63n(int64(j)+1))
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > d {
+		d = apiErr.RetryAfter
+	}
+	return d
+}
+
+//do calls fn until it succeeds, fails permanently or runs out of attempts
+func (p *RetryPolicy) do(ctx context.Context, endpoint string, fn func() error) error {
+	for n := 1; ; n++ {
+		err := fn()
+
+		var delay time.Duration
+		retry := err != nil && n < p.MaxAttempts && p.retryable(err)
+		if retry {
+			delay = p.backoff(n, err)
+		}
+
+		if p.OnAttempt != nil {
+			p.OnAttempt(Attempt{endpoint, n, err, delay})
+		}
+
+		if !retry {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
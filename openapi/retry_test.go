@@ -0,0 +1,109 @@
+package openapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+var fastRetry = openapi.RetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     time.Millisecond,
+	MaxDelay:      5 * time.Millisecond,
+	Jitter:        0.5,
+	RetryStatuses: []int{http.StatusServiceUnavailable},
+}
+
+//newFlakyAPI returns an OpenApi talking to a server that fails with status the first n requests
+func newFlakyAPI(n, status int, retryAfter string, p openapi.RetryPolicy) (openapi.OpenApi, *int, func()) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= n {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		fixtureHandler(w, r)
+	}))
+	return openapi.NewOpenAPI(openapi.WithBaseURL(srv.URL), openapi.WithRetry(p)), &calls, srv.Close
+}
+
+func TestRetrySucceeds(t *testing.T) {
+
+	var attempts []openapi.Attempt
+	p := fastRetry
+	p.OnAttempt = func(a openapi.Attempt) {
+		attempts = append(attempts, a)
+	}
+
+	a, calls, done := newFlakyAPI(2, http.StatusServiceUnavailable, "", p)
+	defer done()
+
+	_, err := a.QueryStation("Malmö")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if *calls != 3 || len(attempts) != 3 {
+		t.Errorf("Expected 3 attempts, got %d calls and %d observed", *calls, len(attempts))
+		return
+	}
+	if attempts[0].Err == nil || attempts[2].Err != nil || attempts[2].Delay != 0 {
+		t.Errorf("Unexpected attempts %v", attempts)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+
+	a, calls, done := newFlakyAPI(10, http.StatusServiceUnavailable, "", fastRetry)
+	defer done()
+
+	_, err := a.QueryStation("Malmö")
+	if !errors.Is(err, openapi.ErrUpstreamUnavailable) || *calls != 3 {
+		t.Errorf("Expected 3 failed calls, got %d: %v", *calls, err)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+
+	a, calls, done := newFlakyAPI(10, http.StatusBadRequest, "", fastRetry)
+	defer done()
+
+	_, err := a.QueryStation("Malmö")
+	if err == nil || *calls != 1 {
+		t.Errorf("Expected 1 failed call, got %d: %v", *calls, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+
+	var delay time.Duration
+	p := fastRetry
+	p.OnAttempt = func(a openapi.Attempt) {
+		if a.Number == 1 {
+			delay = a.Delay
+		}
+	}
+
+	a, _, done := newFlakyAPI(10, http.StatusServiceUnavailable, "1", p)
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := a.QueryStationContext(ctx, "Malmö")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline while waiting, got %v", err)
+	}
+	if delay != time.Second {
+		t.Errorf("Expected Retry-After delay of 1s, got %v", delay)
+	}
+}
@@ -0,0 +1,104 @@
+package openapi
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"time"
+
+	//Embedded zone database, in case the system lacks Europe/Stockholm
+	_ "time/tzdata"
+)
+
+//Stockholm is the time zone of all date-times in the Open API
+var Stockholm = mustLoadLocation("Europe/Stockholm")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+//Layouts of the date-times returned by the Open API, in order of preference
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+/*
+SkanetrafikenTime is a date-time from the Open API.
+
+Upstream sends local times without zone, e.g. "2014-03-10T12:34:00", which
+are parsed in the Europe/Stockholm zone. Times that fall in the gap when
+clocks go forward are moved forward an hour, i.e. 02:30 becomes 03:30 CEST,
+and times repeated when clocks go back are taken as winter time (CET).
+
+It is encoded as RFC 3339 in JSON, or null if zero.
+*/
+type SkanetrafikenTime struct {
+	time.Time
+}
+
+//ParseSkanetrafikenTime parses a date-time in the format used by the Open API
+func ParseSkanetrafikenTime(s string) (SkanetrafikenTime, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return SkanetrafikenTime{}, nil
+	}
+
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, s, Stockholm); err == nil {
+			return SkanetrafikenTime{t}, nil
+		}
+	}
+	return SkanetrafikenTime{}, err
+}
+
+//UnmarshalXML implements xml.Unmarshaler
+func (t *SkanetrafikenTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var s string
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+
+	parsed, err := ParseSkanetrafikenTime(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+//MarshalJSON implements json.Marshaler
+func (t SkanetrafikenTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Format(time.RFC3339))
+}
+
+//UnmarshalJSON implements json.Unmarshaler
+func (t *SkanetrafikenTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = SkanetrafikenTime{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+	*t = SkanetrafikenTime{parsed.In(Stockholm)}
+	return nil
+}
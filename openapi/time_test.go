@@ -0,0 +1,82 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestParseSkanetrafikenTime(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2014-03-10T12:34:00", "2014-03-10T12:34:00+01:00"},
+		{"2014-07-01T08:05:00", "2014-07-01T08:05:00+02:00"},
+		{"2014-07-01 08:05", "2014-07-01T08:05:00+02:00"},
+		{"2014-03-30T01:59:00", "2014-03-30T01:59:00+01:00"},
+		{"2014-03-30T02:30:00", "2014-03-30T03:30:00+02:00"},
+		{"2014-03-30T03:00:00", "2014-03-30T03:00:00+02:00"},
+		{"2014-10-26T02:30:00", "2014-10-26T02:30:00+01:00"},
+	}
+
+	for _, test := range tests {
+		got, err := ParseSkanetrafikenTime(test.in)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if s := got.Format(time.RFC3339); s != test.want {
+			t.Errorf("%s: expected %s, got %s", test.in, test.want, s)
+		}
+	}
+
+	if _, err := ParseSkanetrafikenTime("10/3 12:34"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestSkanetrafikenTimeXML(t *testing.T) {
+
+	var j Journey
+	data := `<Journey><DepDateTime>2014-03-10T12:34:00</DepDateTime><ArrDateTime /></Journey>`
+	if err := xml.Unmarshal([]byte(data), &j); err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2014, 3, 10, 11, 34, 0, 0, time.UTC)
+	if !j.DepDateTime.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, j.DepDateTime)
+	}
+	if !j.ArrDateTime.IsZero() {
+		t.Errorf("Expected zero time, got %v", j.ArrDateTime)
+	}
+}
+
+func TestSkanetrafikenTimeJSON(t *testing.T) {
+
+	in := struct {
+		Dep SkanetrafikenTime
+		Arr SkanetrafikenTime
+	}{}
+	in.Dep, _ = ParseSkanetrafikenTime("2014-03-10T12:34:00")
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte(`{"Dep":"2014-03-10T12:34:00+01:00","Arr":null}`)) {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+
+	out := in
+	if err = json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Dep.Equal(in.Dep.Time) || !out.Arr.IsZero() {
+		t.Errorf("Round trip failed, got %v", out)
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Package server is an HTTP facade for the Open API that serves GeoJSON
+and JSON instead of the original SOAP structures.
+
+	GET /stations?q=Malmö                      GeoJSON of matching stations
+	GET /nearest?lat=55.6&lon=13.0&r=1000      GeoJSON of nearby stations
+	GET /departures/{id}?at=2014-03-10T12:30   JSON departure board
+	GET /journeys?from=Lund&to=Ystad&at=...    JSON journeys
+	GET /journeys/{key}/{seq}/path             GeoJSON journey path
+
+Departures and journeys are GeoJSON too with format=geojson, the stop with
+its departures as properties, and each route link as a LineString.
+With Stops set, /nearest is answered from the local index, without upstream.
+Times are RFC 3339, or local Europe/Stockholm time without zone.
+Errors are returned as {"error": "..."} with a status code mapped from
+the Open API error, e.g. 404 for no results and 502 for upstream failures.
+*/
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+	"github.com/peterstark72/skanetrafiken/stops"
+)
+
+const (
+	ContentTypeJSON    = "application/json; charset=utf-8"
+	ContentTypeGeoJSON = "application/geo+json; charset=utf-8"
+)
+
+//StatusClientClosedRequest is the non-standard status of requests canceled by the client
+const StatusClientClosedRequest = 499
+
+//Server serves the Open API over REST
+type Server struct {
+	API openapi.OpenApi
+	//AllowOrigin is sent as Access-Control-Allow-Origin, empty disables CORS
+	AllowOrigin string
+	//Timeout limits each upstream request, zero for none
+	Timeout time.Duration
+	//Stops answers /nearest locally instead of upstream, if not nil
+	Stops *stops.Index
+
+	mux *http.ServeMux
+}
+
+//New creates a Server using api, allowing requests from any origin
+func New(api openapi.OpenApi) *Server {
+	s := &Server{API: api, AllowOrigin: "*", mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /stations", s.handleStations)
+	s.mux.HandleFunc("GET /nearest", s.handleNearest)
+	s.mux.HandleFunc("GET /departures/{id}", s.handleDepartures)
+	s.mux.HandleFunc("GET /journeys", s.handleJourneys)
+	s.mux.HandleFunc("GET /journeys/{key}/{seq}/path", s.handleJourneyPath)
+	return s
+}
+
+//ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.AllowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", s.AllowOrigin)
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+			w.Header().Set("Access-Control-Max-Age", "86400")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+//context returns the request context, limited by Timeout
+func (s *Server) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if s.Timeout > 0 {
+		return context.WithTimeout(r.Context(), s.Timeout)
+	}
+	return context.WithCancel(r.Context())
+}
+
+//badRequest is an error in the request parameters
+type badRequest struct {
+	msg string
+}
+
+func (e badRequest) Error() string {
+	return e.msg
+}
+
+//StatusCode maps an error to an HTTP status code
+func StatusCode(err error) int {
+	var br badRequest
+	switch {
+	case errors.As(err, &br), errors.Is(err, openapi.ErrOutOfBounds):
+		return http.StatusBadRequest
+	case errors.Is(err, openapi.ErrNoStationsFound),
+		errors.Is(err, openapi.ErrNoJourneysFound),
+		errors.Is(err, openapi.ErrNoDeparturesFound),
+		errors.Is(err, openapi.ErrNoPathFound):
+		return http.StatusNotFound
+	case errors.Is(err, openapi.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, openapi.ErrUpstreamUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, openapi.ErrUpstreamStatus),
+		errors.Is(err, openapi.ErrUnexpectedResponse):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+//writeError writes err as a JSON object, or just the status if the client has gone
+func writeError(w http.ResponseWriter, err error) {
+	status := StatusCode(err)
+	if status == StatusClientClosedRequest {
+		//Nobody is listening
+		w.WriteHeader(status)
+		return
+	}
+
+	body := struct {
+		Error string `json:"error"`
+		Code  int    `json:"code,omitempty"`
+	}{Error: err.Error()}
+
+	var apiErr *openapi.APIError
+	if errors.As(err, &apiErr) {
+		body.Code = apiErr.Code
+	}
+
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+//geoJSONResult is a result that writes plain JSON, and GeoJSON on request
+type geoJSONResult interface {
+	WriteJSON(io.Writer) error
+	openapi.GeoJsonObject
+}
+
+//writeFormat writes res as GeoJSON if the format parameter asks for it, and JSON otherwise
+func writeFormat(w http.ResponseWriter, r *http.Request, res geoJSONResult, err error) {
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeResult(w, ContentTypeJSON, res, err)
+	case "geojson":
+		writeResult(w, ContentTypeGeoJSON, openapi.GeoJsonWriter{GeoJsonObject: res}, err)
+	default:
+		writeError(w, badRequest{"format must be json or geojson"})
+	}
+}
+
+//writeResult writes res with WriteJSON, or the error if any
+func writeResult(w http.ResponseWriter, contentType string, res interface{ WriteJSON(io.Writer) error }, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err = res.WriteJSON(&buf); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
+
+//parseTime parses the at parameter, defaulting to now
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := openapi.ParseSkanetrafikenTime(s)
+	if err != nil {
+		return time.Time{}, badRequest{"invalid time " + strconv.Quote(s)}
+	}
+	return t.Time, nil
+}
+
+func (s *Server) handleStations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeError(w, badRequest{"missing q"})
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	res, err := s.API.QueryStationContext(ctx, q)
+	writeResult(w, ContentTypeGeoJSON, res, err)
+}
+
+func (s *Server) handleNearest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	lat, err1 := strconv.ParseFloat(query.Get("lat"), 64)
+	lon, err2 := strconv.ParseFloat(query.Get("lon"), 64)
+	if err1 != nil || err2 != nil {
+		writeError(w, badRequest{"lat and lon must be numbers"})
+		return
+	}
+
+	radius := 1000
+	if v := query.Get("r"); v != "" {
+		var err error
+		if radius, err = strconv.Atoi(v); err != nil || radius <= 0 {
+			writeError(w, badRequest{"r must be a positive integer"})
+			return
+		}
+	}
+
+	pos := openapi.LatLon{Lat: lat, Lon: lon}
+	if s.Stops != nil {
+		res, err := s.nearestLocal(pos, radius)
+		writeResult(w, ContentTypeGeoJSON, res, err)
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	res, err := s.API.NearestStationLatLonContext(ctx, pos, radius)
+	writeResult(w, ContentTypeGeoJSON, res, err)
+}
+
+//nearestLocal looks up nearby stops in s.Stops, failing like NearestStationLatLon
+func (s *Server) nearestLocal(pos openapi.LatLon, radius int) (res openapi.GetNearestStopAreaResult, err error) {
+	if err = pos.Validate(); err != nil {
+		return res, err
+	}
+	res.NearestStopAreas = s.Stops.Within(pos.ToRT90(), float64(radius))
+	if len(res.NearestStopAreas) == 0 {
+		return res, &openapi.APIError{Endpoint: openapi.NEARESTSTATION, Err: openapi.ErrNoStationsFound}
+	}
+	return res, nil
+}
+
+func (s *Server) handleDepartures(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, badRequest{"id must be a stop area id"})
+		return
+	}
+
+	t, err := parseTime(r.URL.Query().Get("at"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	res, err := s.API.StationResultContext(ctx, id, t)
+	writeFormat(w, r, res, err)
+}
+
+func (s *Server) handleJourneys(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" || to == "" {
+		writeError(w, badRequest{"missing from or to"})
+		return
+	}
+
+	t, err := parseTime(query.Get("at"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	points, err := s.API.QueryPageContext(ctx, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	res, err := s.API.ResultsPageContext(ctx, "next", points.StartPoints[0], points.EndPoints[0], t)
+	writeFormat(w, r, res, err)
+}
+
+func (s *Server) handleJourneyPath(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.Atoi(r.PathValue("seq"))
+	if err != nil {
+		writeError(w, badRequest{"seq must be a journey sequence number"})
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	res, err := s.API.JourneyPathContext(ctx, r.PathValue("key"), seq)
+	writeResult(w, ContentTypeGeoJSON, res, err)
+}
@@ -0,0 +1,261 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+	"github.com/peterstark72/skanetrafiken/server"
+	"github.com/peterstark72/skanetrafiken/stops"
+)
+
+//newTestServer returns a Server whose upstream serves the openapi fixtures
+func newTestServer(t *testing.T) *httptest.Server {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(filepath.Join("..", "openapi", "testdata", path.Base(r.URL.Path)+".xml"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(upstream.Close)
+
+	srv := httptest.NewServer(server.New(openapi.NewOpenAPI(openapi.WithBaseURL(upstream.URL))))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, url string, v interface{}) *http.Response {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if v != nil {
+		if err = json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res
+}
+
+func TestStations(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	var fc struct {
+		Type     string
+		Features []interface{}
+	}
+	res := get(t, srv.URL+"/stations?q=Malm%C3%B6", &fc)
+
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != server.ContentTypeGeoJSON {
+		t.Errorf("Unexpected response %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	if res.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("Missing CORS header")
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 3 {
+		t.Errorf("Unexpected GeoJSON %v", fc)
+	}
+}
+
+func TestNearest(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	var fc struct{ Features []interface{} }
+	res := get(t, srv.URL+"/nearest?lat=55.609&lon=13.0&r=500", &fc)
+	if res.StatusCode != 200 || len(fc.Features) != 2 {
+		t.Errorf("Unexpected response %d %v", res.StatusCode, fc)
+	}
+
+	if res = get(t, srv.URL+"/nearest?lat=north", nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", res.StatusCode)
+	}
+	if res = get(t, srv.URL+"/nearest?lat=13.0&lon=55.609", nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for swapped axes, got %d", res.StatusCode)
+	}
+}
+
+func TestNearestLocal(t *testing.T) {
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected upstream request %s", r.URL)
+	}))
+	defer upstream.Close()
+
+	reg := stops.New()
+	reg.Add(openapi.Point{Name: "Malmö C", Id: 80000, Type: "STOP_AREA", Coord: openapi.Coord{X: 6167946, Y: 1323245}})
+
+	s := server.New(openapi.NewOpenAPI(openapi.WithBaseURL(upstream.URL)))
+	s.Stops = reg.Index()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	var fc struct {
+		Features []struct {
+			Id         string
+			Properties struct{ Distance int }
+		}
+	}
+	res := get(t, srv.URL+"/nearest?lat=55.609&lon=13.0&r=500", &fc)
+	if res.StatusCode != 200 || len(fc.Features) != 1 || fc.Features[0].Id != "nearby:80000" {
+		t.Errorf("Unexpected response %d %v", res.StatusCode, fc)
+	}
+
+	if res = get(t, srv.URL+"/nearest?lat=55.9&lon=13.5&r=500", nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", res.StatusCode)
+	}
+	if res = get(t, srv.URL+"/nearest?lat=13.0&lon=55.609", nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for swapped axes, got %d", res.StatusCode)
+	}
+}
+
+func TestDepartures(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	var lines []struct{ Name string }
+	res := get(t, srv.URL+"/departures/80000?at=2014-03-10T12:30", &lines)
+	if res.StatusCode != 200 || len(lines) != 2 || lines[0].Name != "Öresundståg" {
+		t.Errorf("Unexpected response %d %v", res.StatusCode, lines)
+	}
+
+	if res = get(t, srv.URL+"/departures/80000?at=noon", nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", res.StatusCode)
+	}
+
+	var fc struct {
+		Features []struct {
+			Geometry   struct{ Type string }
+			Properties struct {
+				Name       string
+				Departures []interface{}
+			}
+		}
+	}
+	res = get(t, srv.URL+"/departures/80000?at=2014-03-10T12:30&format=geojson", &fc)
+	if res.Header.Get("Content-Type") != server.ContentTypeGeoJSON || len(fc.Features) != 1 ||
+		fc.Features[0].Geometry.Type != "Point" || len(fc.Features[0].Properties.Departures) != 2 {
+		t.Errorf("Unexpected GeoJSON %d %v", res.StatusCode, fc)
+	}
+
+	if res = get(t, srv.URL+"/departures/80000?format=kml", nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", res.StatusCode)
+	}
+}
+
+func TestJourneys(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	var result struct {
+		JourneyResultKey string
+		Journeys         []interface{}
+	}
+	res := get(t, srv.URL+"/journeys?from=Lund&to=Ystad", &result)
+	if res.StatusCode != 200 || result.JourneyResultKey != "1a2b3c4d" || len(result.Journeys) != 1 {
+		t.Errorf("Unexpected response %d %v", res.StatusCode, result)
+	}
+
+	var links struct {
+		Features []struct {
+			Id       string
+			Geometry struct{ Type string }
+		}
+	}
+	res = get(t, srv.URL+"/journeys?from=Lund&to=Ystad&format=geojson", &links)
+	if res.StatusCode != 200 || len(links.Features) != 1 || links.Features[0].Geometry.Type != "LineString" {
+		t.Errorf("Unexpected GeoJSON %d %v", res.StatusCode, links)
+	}
+
+	var fc struct{ Features []interface{} }
+	res = get(t, srv.URL+"/journeys/1a2b3c4d/0/path", &fc)
+	if res.StatusCode != 200 || len(fc.Features) == 0 {
+		t.Errorf("Unexpected response %d %v", res.StatusCode, fc)
+	}
+}
+
+func TestUpstreamFailure(t *testing.T) {
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	srv := httptest.NewServer(server.New(openapi.NewOpenAPI(openapi.WithBaseURL(upstream.URL))))
+	defer srv.Close()
+
+	var body struct{ Error string }
+	res := get(t, srv.URL+"/stations?q=Lund", &body)
+	if res.StatusCode != http.StatusServiceUnavailable || body.Error == "" {
+		t.Errorf("Expected 503 with error, got %d %v", res.StatusCode, body)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: 99, 99", openapi.ErrOutOfBounds), http.StatusBadRequest},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: openapi.ErrNoStationsFound}, http.StatusNotFound},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: openapi.ErrRateLimited}, http.StatusTooManyRequests},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: context.DeadlineExceeded}, http.StatusGatewayTimeout},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: fmt.Errorf("%w: %w", openapi.ErrUpstreamUnavailable, context.Canceled)}, server.StatusClientClosedRequest},
+		{context.Canceled, server.StatusClientClosedRequest},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: openapi.ErrUpstreamUnavailable}, http.StatusServiceUnavailable},
+		{&openapi.APIError{Endpoint: openapi.QUERYSTATION, Err: openapi.ErrUnexpectedResponse}, http.StatusBadGateway},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if code := server.StatusCode(tt.err); code != tt.want {
+			t.Errorf("StatusCode(%v): expected %d, got %d", tt.err, tt.want, code)
+		}
+	}
+}
+
+func TestClientCanceled(t *testing.T) {
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Canceled request reached upstream")
+	}))
+	defer upstream.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/stations?q=Lund", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	server.New(openapi.NewOpenAPI(openapi.WithBaseURL(upstream.URL))).ServeHTTP(rec, req)
+
+	if rec.Code != server.StatusClientClosedRequest || rec.Body.Len() != 0 {
+		t.Errorf("Expected 499 without body, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPreflight(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodOptions, srv.URL+"/stations", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent || res.Header.Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("Unexpected preflight response %d %v", res.StatusCode, res.Header)
+	}
+}
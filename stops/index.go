@@ -0,0 +1,305 @@
+package stops
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//gridMargin widens grid searches to cover the difference between RT90 grid
+//distances and distances on the ellipsoid, which is well below 0.1% in Skåne
+const gridMargin = 1.001
+
+/*
+Index is a k-d tree over the RT90 coordinates of points, for nearest stop
+queries without a request. It is immutable, and safe for concurrent use.
+
+Distances are searched on the RT90 grid, and reported on the ellipsoid.
+*/
+type Index struct {
+	points []openapi.Point
+}
+
+/*
+NewIndex builds an Index of points. Points without coordinates are left
+out, so are points outside SkaneBounds, since the RT90 grid distorts
+distances far away from it.
+*/
+func NewIndex(points []openapi.Point) *Index {
+	ix := &Index{}
+	for _, p := range points {
+		if p.X > 0 && p.Y > 0 && p.Validate() == nil {
+			ix.points = append(ix.points, p)
+		}
+	}
+	build(ix.points, 0)
+	return ix
+}
+
+//Index builds an Index of the stop areas in the registry
+func (r *Registry) Index() *Index {
+	var points []openapi.Point
+	for _, p := range r.Points() {
+		if p.Type == "STOP_AREA" {
+			points = append(points, p)
+		}
+	}
+	return NewIndex(points)
+}
+
+//Len returns the number of points in the index
+func (ix *Index) Len() int {
+	return len(ix.points)
+}
+
+//axis returns the X or Y coordinate of c, alternating by depth
+func axis(c openapi.Coord, depth int) float64 {
+	if depth%2 == 0 {
+		return c.X
+	}
+	return c.Y
+}
+
+//build sorts points into an implicit k-d tree, where the median of each range is its node
+func build(points []openapi.Point, depth int) {
+	if len(points) < 2 {
+		return
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return axis(points[i].Coord, depth) < axis(points[j].Coord, depth)
+	})
+	m := len(points) / 2
+	build(points[:m], depth+1)
+	build(points[m+1:], depth+1)
+}
+
+func gridDistance2(a, b openapi.Coord) float64 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
+
+//visit calls f for the points of the tree within the grid box [min, max],
+//pruning subtrees outside it
+func visit(points []openapi.Point, depth int, min, max openapi.Coord, f func(openapi.Point)) {
+	if len(points) == 0 {
+		return
+	}
+	m := len(points) / 2
+	p := points[m]
+	v := axis(p.Coord, depth)
+
+	if p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y {
+		f(p)
+	}
+	if axis(min, depth) <= v {
+		visit(points[:m], depth+1, min, max, f)
+	}
+	if axis(max, depth) >= v {
+		visit(points[m+1:], depth+1, min, max, f)
+	}
+}
+
+//candidate is a point with its squared grid distance, or distance in meters, to the query
+type candidate struct {
+	openapi.Point
+	dist float64
+}
+
+//candidates is a max-heap on distance, holding the k nearest points found so far
+type candidates []candidate
+
+func (c candidates) Len() int            { return len(c) }
+func (c candidates) Less(i, j int) bool  { return c[i].dist > c[j].dist }
+func (c candidates) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *candidates) Push(x interface{}) { *c = append(*c, x.(candidate)) }
+func (c *candidates) Pop() interface{} {
+	old := *c
+	e := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return e
+}
+
+func nearest(points []openapi.Point, depth int, c openapi.Coord, k int, found *candidates) {
+	if len(points) == 0 {
+		return
+	}
+	m := len(points) / 2
+	p := points[m]
+
+	if d := gridDistance2(p.Coord, c); found.Len() < k {
+		heap.Push(found, candidate{p, d})
+	} else if d < (*found)[0].dist {
+		(*found)[0] = candidate{p, d}
+		heap.Fix(found, 0)
+	}
+
+	diff := axis(c, depth) - axis(p.Coord, depth)
+	near, far := points[:m], points[m+1:]
+	if diff > 0 {
+		near, far = far, near
+	}
+	nearest(near, depth+1, c, k, found)
+	if found.Len() < k || diff*diff < (*found)[0].dist*gridMargin*gridMargin {
+		nearest(far, depth+1, c, k, found)
+	}
+}
+
+//stopAreas returns points as NearestStopAreas with their distance to c, nearest first
+func stopAreas(points []openapi.Point, c openapi.Coord) []openapi.NearestStopArea {
+	found := make([]candidate, len(points))
+	for n, p := range points {
+		found[n] = candidate{p, c.Distance(p.Coord)}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].dist != found[j].dist {
+			return found[i].dist < found[j].dist
+		}
+		return found[i].Id < found[j].Id
+	})
+
+	areas := make([]openapi.NearestStopArea, len(found))
+	for n, f := range found {
+		areas[n] = openapi.NearestStopArea{Point: f.Point, Distance: int(math.Round(f.dist))}
+	}
+	return areas
+}
+
+/*
+Nearest returns the k points nearest to c, nearest first. The k nearest
+on the grid only give the search radius, which is widened by gridMargin,
+and the candidates within it are ranked on the ellipsoid.
+*/
+func (ix *Index) Nearest(c openapi.Coord, k int) []openapi.NearestStopArea {
+	if k <= 0 {
+		return nil
+	}
+	var found candidates
+	nearest(ix.points, 0, c, k, &found)
+	if found.Len() < k {
+		points := make([]openapi.Point, len(found))
+		for n, f := range found {
+			points[n] = f.Point
+		}
+		return stopAreas(points, c)
+	}
+
+	r := math.Sqrt(found[0].dist) * gridMargin
+	areas := ix.within(c, r, func(p openapi.Point) bool { return true })
+	return areas[:k]
+}
+
+//Within returns the points within radius meters of c, nearest first
+func (ix *Index) Within(c openapi.Coord, radius float64) []openapi.NearestStopArea {
+	return ix.within(c, radius*gridMargin, func(p openapi.Point) bool {
+		return c.Distance(p.Coord) <= radius
+	})
+}
+
+//within returns the points within the grid radius r of c that keep holds for, nearest first
+func (ix *Index) within(c openapi.Coord, r float64, keep func(openapi.Point) bool) []openapi.NearestStopArea {
+	min, max := openapi.Coord{X: c.X - r, Y: c.Y - r}, openapi.Coord{X: c.X + r, Y: c.Y + r}
+
+	var points []openapi.Point
+	visit(ix.points, 0, min, max, func(p openapi.Point) {
+		if gridDistance2(p.Coord, c) <= r*r && keep(p) {
+			points = append(points, p)
+		}
+	})
+	return stopAreas(points, c)
+}
+
+//gridEnvelope returns the RT90 box around positions, widened by a meter for rounding
+func gridEnvelope(positions []openapi.LatLon) (min, max openapi.Coord) {
+	min = openapi.Coord{X: math.Inf(1), Y: math.Inf(1)}
+	max = openapi.Coord{X: math.Inf(-1), Y: math.Inf(-1)}
+	for _, p := range positions {
+		c := p.ToRT90()
+		min.X, min.Y = math.Min(min.X, c.X), math.Min(min.Y, c.Y)
+		max.X, max.Y = math.Max(max.X, c.X), math.Max(max.Y, c.Y)
+	}
+	return openapi.Coord{X: min.X - 1, Y: min.Y - 1}, openapi.Coord{X: max.X + 1, Y: max.Y + 1}
+}
+
+/*
+InBounds returns the points within the WGS84 bounding box b, nearest to
+its center first, with their distance to it.
+*/
+func (ix *Index) InBounds(b openapi.Bounds) []openapi.NearestStopArea {
+
+	//Parallels are curved on the grid, so the envelope includes points along the edges
+	var edges []openapi.LatLon
+	for i := 0; i <= 8; i++ {
+		lat := b.Min.Lat + (b.Max.Lat-b.Min.Lat)*float64(i)/8
+		lon := b.Min.Lon + (b.Max.Lon-b.Min.Lon)*float64(i)/8
+		edges = append(edges,
+			openapi.LatLon{Lat: lat, Lon: b.Min.Lon}, openapi.LatLon{Lat: lat, Lon: b.Max.Lon},
+			openapi.LatLon{Lat: b.Min.Lat, Lon: lon}, openapi.LatLon{Lat: b.Max.Lat, Lon: lon})
+	}
+	min, max := gridEnvelope(edges)
+
+	var points []openapi.Point
+	visit(ix.points, 0, min, max, func(p openapi.Point) {
+		if b.Contains(p.ToWGS84()) {
+			points = append(points, p)
+		}
+	})
+	center := openapi.LatLon{Lat: (b.Min.Lat + b.Max.Lat) / 2, Lon: (b.Min.Lon + b.Max.Lon) / 2}
+	return stopAreas(points, center.ToRT90())
+}
+
+/*
+InPolygon returns the points within the WGS84 polygon, nearest to its
+centroid first, with their distance to it. The polygon is a ring of
+positions, closed or not, whose edges are straight lines in latitude
+and longitude.
+*/
+func (ix *Index) InPolygon(polygon []openapi.LatLon) []openapi.NearestStopArea {
+	if len(polygon) < 3 {
+		return nil
+	}
+	min, max := gridEnvelope(polygon)
+
+	var points []openapi.Point
+	visit(ix.points, 0, min, max, func(p openapi.Point) {
+		if inPolygon(p.ToWGS84(), polygon) {
+			points = append(points, p)
+		}
+	})
+	return stopAreas(points, centroid(polygon).ToRT90())
+}
+
+//centroid returns the centroid of the area of polygon, or the mean of its positions if it has none
+func centroid(polygon []openapi.LatLon) openapi.LatLon {
+	var area, lat, lon float64
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[j], polygon[i]
+		cross := a.Lon*b.Lat - b.Lon*a.Lat
+		area += cross
+		lat += (a.Lat + b.Lat) * cross
+		lon += (a.Lon + b.Lon) * cross
+	}
+	if area == 0 {
+		lat, lon = 0, 0
+		for _, p := range polygon {
+			lat += p.Lat
+			lon += p.Lon
+		}
+		return openapi.LatLon{Lat: lat / float64(len(polygon)), Lon: lon / float64(len(polygon))}
+	}
+	return openapi.LatLon{Lat: lat / (3 * area), Lon: lon / (3 * area)}
+}
+
+//inPolygon tells if p is inside polygon, by counting the edges crossed by a ray going east
+func inPolygon(p openapi.LatLon, polygon []openapi.LatLon) bool {
+	in := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < a.Lon+(p.Lat-a.Lat)*(b.Lon-a.Lon)/(b.Lat-a.Lat) {
+			in = !in
+		}
+	}
+	return in
+}
@@ -0,0 +1,238 @@
+package stops
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//randomPoints returns n stop areas spread over Skåne
+func randomPoints(n int) []openapi.Point {
+	rnd := rand.New(rand.NewSource(1))
+	points := make([]openapi.Point, n)
+	for i := range points {
+		p := openapi.LatLon{Lat: 55.4 + rnd.Float64()*0.9, Lon: 12.6 + rnd.Float64()*1.8}
+		points[i] = openapi.Point{Name: "Stop", Id: i + 1, Type: "STOP_AREA", Coord: p.ToRT90()}
+	}
+	return points
+}
+
+//bruteForce returns the ids of points within radius of c, nearest first
+func bruteForce(points []openapi.Point, c openapi.Coord, radius float64) []int {
+	var ids []int
+	for _, p := range points {
+		if c.Distance(p.Coord) <= radius {
+			ids = append(ids, p.Id)
+		}
+	}
+	byId := make(map[int]openapi.Point)
+	for _, p := range points {
+		byId[p.Id] = p
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return c.Distance(byId[ids[i]].Coord) < c.Distance(byId[ids[j]].Coord)
+	})
+	return ids
+}
+
+func ids(areas []openapi.NearestStopArea) []int {
+	ids := make([]int, len(areas))
+	for n, a := range areas {
+		ids[n] = a.Id
+	}
+	return ids
+}
+
+func equalIds(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndexNearest(t *testing.T) {
+
+	points := randomPoints(2000)
+	ix := NewIndex(points)
+
+	malmo := openapi.LatLon{Lat: 55.609, Lon: 13.0}.ToRT90()
+	for _, k := range []int{1, 5, 50} {
+		got := ix.Nearest(malmo, k)
+		want := bruteForce(points, malmo, 1e9)[:k]
+		if !equalIds(ids(got), want) {
+			t.Errorf("k=%d: expected %v, got %v", k, want, ids(got))
+		}
+	}
+
+	//Distances are on the ellipsoid, rounded to meters
+	got := ix.Nearest(malmo, 1)
+	if d := malmo.Distance(got[0].Coord); got[0].Distance != int(d+0.5) {
+		t.Errorf("Expected distance %.1f, got %d", d, got[0].Distance)
+	}
+
+	if got = ix.Nearest(malmo, 0); got != nil {
+		t.Errorf("Expected no points, got %v", got)
+	}
+	if got = ix.Nearest(malmo, 5000); len(got) != len(points) {
+		t.Errorf("Expected all %d points, got %d", len(points), len(got))
+	}
+}
+
+func TestIndexNearestOnEllipsoid(t *testing.T) {
+
+	//Two stops as far from c on the grid, west and east, are not as far on the ellipsoid
+	c := openapi.LatLon{Lat: 55.609, Lon: 13.0}.ToRT90()
+	west := openapi.Point{Name: "West", Id: 1, Coord: openapi.Coord{X: c.X, Y: c.Y - 1000}}
+	east := openapi.Point{Name: "East", Id: 2, Coord: openapi.Coord{X: c.X, Y: c.Y + 1000}}
+
+	want := west
+	if c.Distance(east.Coord) < c.Distance(west.Coord) {
+		want = east
+	}
+	for _, points := range [][]openapi.Point{{west, east}, {east, west}} {
+		if got := NewIndex(points).Nearest(c, 1); len(got) != 1 || got[0].Id != want.Id {
+			t.Errorf("Expected %s, got %v", want.Name, got)
+		}
+	}
+}
+
+func TestIndexWithin(t *testing.T) {
+
+	points := randomPoints(2000)
+	ix := NewIndex(points)
+
+	lund := openapi.LatLon{Lat: 55.705, Lon: 13.187}.ToRT90()
+	for _, radius := range []float64{0, 500, 2000, 10000} {
+		got := ix.Within(lund, radius)
+		want := bruteForce(points, lund, radius)
+		if !equalIds(ids(got), want) {
+			t.Errorf("Radius %.0f: expected %v, got %v", radius, want, ids(got))
+		}
+		for _, a := range got {
+			if float64(a.Distance) > radius+0.5 {
+				t.Errorf("Radius %.0f: %d is %d m away", radius, a.Id, a.Distance)
+			}
+		}
+	}
+}
+
+func TestIndexInBounds(t *testing.T) {
+
+	points := randomPoints(2000)
+	ix := NewIndex(points)
+
+	b := openapi.Bounds{Min: openapi.LatLon{Lat: 55.55, Lon: 12.9}, Max: openapi.LatLon{Lat: 55.75, Lon: 13.3}}
+	var inside []openapi.Point
+	for _, p := range points {
+		if b.Contains(p.ToWGS84()) {
+			inside = append(inside, p)
+		}
+	}
+
+	//Nearest to the center first
+	center := openapi.LatLon{Lat: 55.65, Lon: 13.1}.ToRT90()
+	want := bruteForce(inside, center, 1e9)
+	got := ix.InBounds(b)
+	if len(want) == 0 || !equalIds(ids(got), want) {
+		t.Errorf("Expected %v, got %v", want, ids(got))
+	}
+	if d := center.Distance(got[0].Coord); got[0].Distance != int(d+0.5) {
+		t.Errorf("Expected distance %.1f, got %d", d, got[0].Distance)
+	}
+}
+
+func TestIndexInPolygon(t *testing.T) {
+
+	points := randomPoints(2000)
+	ix := NewIndex(points)
+
+	//A triangle between Malmö, Lund and Trelleborg
+	triangle := []openapi.LatLon{{Lat: 55.609, Lon: 13.0}, {Lat: 55.705, Lon: 13.187}, {Lat: 55.375, Lon: 13.157}}
+
+	got := ix.InPolygon(triangle)
+	if len(got) == 0 {
+		t.Fatal("Expected points in the triangle")
+	}
+	for _, p := range got {
+		if !inPolygon(p.ToWGS84(), triangle) {
+			t.Errorf("%d is outside the triangle", p.Id)
+		}
+	}
+	//All points inside are found, closing the ring changes nothing
+	closed := append(triangle, triangle[0])
+	if n := len(ix.InPolygon(closed)); n != len(got) {
+		t.Errorf("Expected %d points in the closed ring, got %d", len(got), n)
+	}
+	n := 0
+	for _, p := range points {
+		if inPolygon(p.ToWGS84(), triangle) {
+			n++
+		}
+	}
+	if n != len(got) {
+		t.Errorf("Expected %d points, got %d", n, len(got))
+	}
+
+	//Nearest to the centroid first
+	c := centroid(triangle).ToRT90()
+	for n := 1; n < len(got); n++ {
+		if got[n].Distance < got[n-1].Distance {
+			t.Errorf("Expected nearest to the centroid first, got %d before %d", got[n-1].Distance, got[n].Distance)
+		}
+	}
+	if d := c.Distance(got[0].Coord); got[0].Distance != int(d+0.5) {
+		t.Errorf("Expected distance %.1f, got %d", d, got[0].Distance)
+	}
+
+	if got = ix.InPolygon(triangle[:2]); got != nil {
+		t.Errorf("Expected no points for a degenerate polygon, got %v", got)
+	}
+}
+
+func TestCentroid(t *testing.T) {
+
+	square := []openapi.LatLon{{Lat: 55, Lon: 13}, {Lat: 55, Lon: 14}, {Lat: 56, Lon: 14}, {Lat: 56, Lon: 13}}
+	tests := []struct {
+		polygon []openapi.LatLon
+		want    openapi.LatLon
+	}{
+		{square, openapi.LatLon{Lat: 55.5, Lon: 13.5}},
+		{append(square, square[0]), openapi.LatLon{Lat: 55.5, Lon: 13.5}},
+		{[]openapi.LatLon{{Lat: 55, Lon: 13}, {Lat: 55, Lon: 16}, {Lat: 58, Lon: 13}}, openapi.LatLon{Lat: 56, Lon: 14}},
+		{[]openapi.LatLon{{Lat: 55, Lon: 13}, {Lat: 56, Lon: 14}, {Lat: 57, Lon: 15}}, openapi.LatLon{Lat: 56, Lon: 14}},
+	}
+	for _, tt := range tests {
+		c := centroid(tt.polygon)
+		if math.Abs(c.Lat-tt.want.Lat) > 1e-9 || math.Abs(c.Lon-tt.want.Lon) > 1e-9 {
+			t.Errorf("centroid(%v): expected %v, got %v", tt.polygon, tt.want, c)
+		}
+	}
+}
+
+func TestRegistryIndex(t *testing.T) {
+
+	r := New()
+	r.Add(testPoints...)
+	r.Add(point("Nowhere", 1, "STOP_AREA", 0, 0), point("Stockholm C", 2, "STOP_AREA", 6580994, 1628294))
+
+	ix := r.Index()
+
+	//The POI, the stop without coordinates and the one outside Skåne are left out
+	if ix.Len() != 6 {
+		t.Errorf("Expected 6 stop areas, got %d", ix.Len())
+	}
+
+	c := openapi.Coord{X: 6167930, Y: 1323215}
+	got := ix.Within(c, 1000)
+	if len(got) != 1 || got[0].Id != 80000 || got[0].Distance != 34 {
+		t.Errorf("Unexpected stop areas %v", got)
+	}
+}
@@ -0,0 +1,204 @@
+package stops
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//Ranks of a match, lower is better. Fuzzy matches add their number of edits.
+const (
+	RankExact      = 0
+	RankPrefix     = 10
+	RankWordPrefix = 20
+	RankSubstring  = 30
+	RankFuzzy      = 40
+)
+
+//Match is a point found by Search
+type Match struct {
+	openapi.Point
+	Rank int
+}
+
+//folds maps letters with diacritics to their base letters
+var folds = map[rune]string{
+	'å': "a", 'ä': "a", 'à': "a", 'á': "a", 'â': "a", 'ã': "a",
+	'æ': "ae", 'ç': "c",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i",
+	'ñ': "n",
+	'ö': "o", 'ø': "o", 'ó': "o", 'ò': "o", 'ô': "o", 'õ': "o",
+	'ü': "u", 'ú': "u", 'ù': "u", 'û': "u",
+	'ý': "y", 'ÿ': "y",
+	'ß': "ss",
+}
+
+//normalize lower-cases s, folds diacritics and turns everything but letters and digits into single spaces
+func normalize(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, c := range strings.ToLower(s) {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			space = sb.Len() > 0
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		if f, ok := folds[c]; ok {
+			sb.WriteString(f)
+		} else {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func words(s string) []string {
+	return strings.Fields(s)
+}
+
+//maxEdits is the number of typos tolerated in a query word, none for short words
+func maxEdits(word string) int {
+	switch n := len(word); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+//distances returns the optimal string alignment distances between a and
+//every prefix of b, where a transposition of two adjacent letters is one edit
+func distances(a, b string) []int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)]
+}
+
+//prefixDistance is the fewest edits that turn q into a prefix of word
+func prefixDistance(q, word string) int {
+	return slices.Min(distances(q, word))
+}
+
+//matchWords matches every query word with a different name word, each
+//within maxEdits of a prefix of it, and returns the total number of edits
+func matchWords(query, name []string, fuzzy bool) (int, bool) {
+	used := make([]bool, len(name))
+	total := 0
+	for _, q := range query {
+		best, bestWord := -1, -1
+		for n, w := range name {
+			if used[n] {
+				continue
+			}
+			d := 0
+			if !strings.HasPrefix(w, q) {
+				if !fuzzy {
+					continue
+				}
+				if d = prefixDistance(q, w); d > maxEdits(q) {
+					continue
+				}
+			}
+			if best < 0 || d < best {
+				best, bestWord = d, n
+			}
+		}
+		if bestWord < 0 {
+			return 0, false
+		}
+		used[bestWord] = true
+		total += best
+	}
+	return total, true
+}
+
+//rank returns the rank of e for the normalized query, or false if it does not match
+func (e *entry) rank(query string, qwords []string) (int, bool) {
+	switch {
+	case e.name == query:
+		return RankExact, true
+	case strings.HasPrefix(e.name, query):
+		return RankPrefix, true
+	}
+	if _, ok := matchWords(qwords, e.words, false); ok {
+		return RankWordPrefix, true
+	}
+	if strings.Contains(e.name, query) {
+		return RankSubstring, true
+	}
+	if edits, ok := matchWords(qwords, e.words, true); ok {
+		return RankFuzzy + edits, true
+	}
+	return 0, false
+}
+
+/*
+Search returns up to limit points matching query, best first, or all
+matches if limit is 0. Matches are ranked as exact names, name prefixes,
+word prefixes, e.g. "c malmo" for "Malmö C", substrings and last fuzzy
+matches by their number of typos. Ties prefer stop areas, then shorter
+names.
+*/
+func (r *Registry) Search(query string, limit int) []Match {
+	q := normalize(query)
+	if q == "" {
+		return nil
+	}
+	qwords := words(q)
+
+	r.mu.RLock()
+	var matches []Match
+	for _, e := range r.points {
+		if rank, ok := e.rank(q, qwords); ok {
+			matches = append(matches, Match{e.Point, rank})
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case a.Rank != b.Rank:
+			return a.Rank < b.Rank
+		case (a.Type == "STOP_AREA") != (b.Type == "STOP_AREA"):
+			return a.Type == "STOP_AREA"
+		case len(a.Name) != len(b.Name):
+			return len(a.Name) < len(b.Name)
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		}
+		return a.Id < b.Id
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,86 @@
+package stops
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+
+	tests := []struct{ in, want string }{
+		{"Malmö C", "malmo c"},
+		{"  Malmö,  Malmö C ", "malmo malmo c"},
+		{"Ängelholm/Hässleholm", "angelholm hassleholm"},
+		{"Köpenhamn Österport", "kopenhamn osterport"},
+		{"Ørestad", "orestad"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalize(tt.in); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestPrefixDistance(t *testing.T) {
+
+	tests := []struct {
+		q, word string
+		want    int
+	}{
+		{"malmo", "malmo", 0},
+		{"mal", "malmo", 0},
+		{"malno", "malmo", 1},
+		{"lnud", "lund", 1},
+		{"centarl", "centralstation", 1},
+		{"xyz", "lund", 3},
+	}
+	for _, tt := range tests {
+		if d := prefixDistance(tt.q, tt.word); d != tt.want {
+			t.Errorf("%s/%s: expected %d, got %d", tt.q, tt.word, tt.want, d)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+
+	r := New()
+	r.Add(testPoints...)
+
+	tests := []struct {
+		query string
+		first string
+		rank  int
+		n     int
+	}{
+		{"Malmö C", "Malmö C", RankExact, 2},
+		{"malmo", "Malmö C", RankPrefix, 4},
+		{"c malmö", "Malmö C", RankWordPrefix, 2},
+		{"hyllie", "Malmö Hyllie", RankWordPrefix, 1},
+		{"angeln", "Malmö Triangeln", RankSubstring, 1},
+		{"malnö hylie", "Malmö Hyllie", RankFuzzy + 2, 1},
+		{"lund centarl", "Lund Centralstation", RankFuzzy + 1, 1},
+		{"lnud", "Lund C", RankFuzzy + 1, 2},
+		{"lun", "Lund C", RankPrefix, 2},
+		{"lnu", "", 0, 0}, // Too short for typos
+		{"", "", 0, 0},
+	}
+	for _, tt := range tests {
+		m := r.Search(tt.query, 0)
+		if len(m) != tt.n {
+			t.Errorf("%q: expected %d matches, got %v", tt.query, tt.n, m)
+			continue
+		}
+		if tt.n > 0 && (m[0].Name != tt.first || m[0].Rank != tt.rank) {
+			t.Errorf("%q: expected %s with rank %d, got %s with rank %d", tt.query, tt.first, tt.rank, m[0].Name, m[0].Rank)
+		}
+	}
+
+	//Stop areas go before other points of the same rank, and limit cuts the list
+	m := r.Search("malmo c", 0)
+	if len(m) != 2 || m[0].Type != "STOP_AREA" || m[1].Type != "POI" {
+		t.Errorf("Unexpected order %v", m)
+	}
+	if m = r.Search("malmo", 2); len(m) != 2 {
+		t.Errorf("Expected 2 matches, got %d", len(m))
+	}
+}
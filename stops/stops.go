@@ -0,0 +1,188 @@
+/*
+Package stops is an offline registry of Open API points, for station
+lookups without a round trip per keystroke.
+
+Harvest points from the API, or Add them from any result, then Search
+them by name. Search is prefix, diacritic-insensitive and typo-tolerant,
+so "malmo", "Malmö C", "c malmö" and "malnö" all find "Malmö C", and
+"lund centarl" finds "Lund Centralstation":
+
+	reg := stops.New()
+	reg.Harvest(ctx, api, "Malmö", "Lund", "Helsingborg")
+	matches := reg.Search("malmo", 10)
+
+Save and Load persist the registry as JSON, so it can be built once and
+shipped with the application:
+
+	reg.SaveFile("stops.json")
+	reg, err := stops.LoadFile("stops.json")
+
+Index builds a spatial index of the stop areas, for nearest, radius,
+bounding box and polygon queries:
+
+	near := reg.Index().Nearest(coord, 5)
+*/
+package stops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+//key identifies a point, ids are only unique per type
+type key struct {
+	Type string
+	Id   int
+}
+
+//entry is a point with its normalized name, computed once when added
+type entry struct {
+	openapi.Point
+	name  string
+	words []string
+}
+
+/*
+Registry is an in-memory index of points. It is safe for concurrent use.
+*/
+type Registry struct {
+	mu     sync.RWMutex
+	points map[key]*entry
+}
+
+//New creates an empty Registry
+func New() *Registry {
+	return &Registry{points: make(map[key]*entry)}
+}
+
+//Add adds points to the registry, replacing earlier points with the same type and id.
+//Points without a name are ignored.
+func (r *Registry) Add(points ...openapi.Point) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range points {
+		if p.Name == "" {
+			continue
+		}
+		k := key{p.Type, p.Id}
+		//Keep known coordinates when the new point lacks them, e.g. from NewPointFromURIParameter
+		if old, ok := r.points[k]; ok && p.X == 0 && p.Y == 0 {
+			p.Coord = old.Coord
+		}
+		name := normalize(p.Name)
+		r.points[k] = &entry{p, name, words(name)}
+	}
+}
+
+//Len returns the number of points in the registry
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.points)
+}
+
+//Lookup returns the stop area with id
+func (r *Registry) Lookup(id int) (openapi.Point, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.points[key{"STOP_AREA", id}]
+	if !ok {
+		return openapi.Point{}, false
+	}
+	return e.Point, true
+}
+
+//Points returns all points, sorted by name and id
+func (r *Registry) Points() []openapi.Point {
+	r.mu.RLock()
+	points := make([]openapi.Point, 0, len(r.points))
+	for _, e := range r.points {
+		points = append(points, e.Point)
+	}
+	r.mu.RUnlock()
+
+	sortPoints(points)
+	return points
+}
+
+//sortPoints sorts points by name, type and id
+func sortPoints(points []openapi.Point) {
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Name != points[j].Name {
+			return points[i].Name < points[j].Name
+		}
+		if points[i].Type != points[j].Type {
+			return points[i].Type < points[j].Type
+		}
+		return points[i].Id < points[j].Id
+	})
+}
+
+/*
+Harvest adds the stations found by QueryStation for each query. Queries
+without stations are skipped, other errors stop the harvest and are
+returned, keeping the points harvested so far.
+*/
+func (r *Registry) Harvest(ctx context.Context, api openapi.OpenApi, queries ...string) error {
+	for _, q := range queries {
+		res, err := api.QueryStationContext(ctx, q)
+		if errors.Is(err, openapi.ErrNoStationsFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.Add(res.StartPoints...)
+	}
+	return nil
+}
+
+//Save writes the points of the registry as a JSON array
+func (r *Registry) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(r.Points())
+}
+
+//SaveFile writes the registry to the named file, see Save
+func (r *Registry) SaveFile(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err = r.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//Load reads a registry written by Save
+func Load(rd io.Reader) (*Registry, error) {
+	var points []openapi.Point
+	if err := json.NewDecoder(rd).Decode(&points); err != nil {
+		return nil, err
+	}
+	r := New()
+	r.Add(points...)
+	return r, nil
+}
+
+//LoadFile reads a registry from the named file, see Load
+func LoadFile(name string) (*Registry, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
@@ -0,0 +1,117 @@
+package stops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterstark72/skanetrafiken/openapi"
+)
+
+func point(name string, id int, typ string, x, y float64) openapi.Point {
+	return openapi.Point{Name: name, Id: id, Type: typ, Coord: openapi.Coord{X: x, Y: y}}
+}
+
+var testPoints = []openapi.Point{
+	point("Malmö C", 80000, "STOP_AREA", 6167946, 1323245),
+	point("Malmö Hyllie", 80100, "STOP_AREA", 6159609, 1320917),
+	point("Malmö Triangeln", 80120, "STOP_AREA", 6166288, 1323880),
+	point("Lund C", 81216, "STOP_AREA", 6175867, 1335132),
+	point("Lund Centralstation", 81217, "STOP_AREA", 6175870, 1335130),
+	point("Landskrona", 82000, "STOP_AREA", 6197478, 1311283),
+	point("Malmö, Malmö C", 80000, "POI", 6167950, 1323250),
+}
+
+func TestAdd(t *testing.T) {
+
+	r := New()
+	r.Add(testPoints...)
+	r.Add(openapi.Point{Id: 1}) // No name
+
+	if r.Len() != len(testPoints) {
+		t.Errorf("Expected %d points, got %d", len(testPoints), r.Len())
+	}
+
+	//Replacing a point without coordinates keeps the known ones
+	r.Add(point("Malmö Central", 80000, "STOP_AREA", 0, 0))
+	p, ok := r.Lookup(80000)
+	if !ok || p.Name != "Malmö Central" || p.X != 6167946 {
+		t.Errorf("Unexpected point %v", p)
+	}
+
+	if _, ok = r.Lookup(99999); ok {
+		t.Error("Expected unknown stop area")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+
+	r := New()
+	r.Add(testPoints...)
+
+	name := filepath.Join(t.TempDir(), "stops.json")
+	if err := r.SaveFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var a, b bytes.Buffer
+	r.Save(&a)
+	loaded.Save(&b)
+	if a.String() != b.String() {
+		t.Errorf("Loaded registry differs:\n%s\n%s", a.String(), b.String())
+	}
+
+	if m := loaded.Search("hyllie", 1); len(m) != 1 || m[0].Id != 80100 {
+		t.Errorf("Unexpected search result %v", m)
+	}
+
+	if _, err = Load(bytes.NewBufferString("{")); err == nil {
+		t.Error("Expected error for malformed file")
+	}
+}
+
+func TestHarvest(t *testing.T) {
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(filepath.Join("..", "openapi", "testdata", path.Base(r.URL.Path)+".xml"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer upstream.Close()
+
+	r := New()
+	if err := r.Harvest(context.Background(), openapi.NewOpenAPI(openapi.WithBaseURL(upstream.URL)), "Malmö"); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := r.Lookup(80120); r.Len() != 3 || !ok || p.Name != "Malmö Triangeln" {
+		t.Errorf("Unexpected registry %v", r.Points())
+	}
+
+	//Upstream errors stop the harvest
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	failing := openapi.NewOpenAPI(openapi.WithBaseURL(unavailable.URL))
+	if err := r.Harvest(context.Background(), failing, "Lund"); !errors.Is(err, openapi.ErrUpstreamUnavailable) {
+		t.Errorf("Expected ErrUpstreamUnavailable, got %v", err)
+	}
+	if r.Len() != 3 {
+		t.Errorf("Expected the harvested points to be kept, got %d", r.Len())
+	}
+}